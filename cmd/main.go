@@ -10,6 +10,7 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/Egorpalan/workmate-test/config"
 	"github.com/Egorpalan/workmate-test/internal/delivery/http"
@@ -46,11 +47,29 @@ func main() {
 		logger.Fatal("Database ping failed", zap.Error(err))
 	}
 
-	taskRepo := postgresql.NewTaskRepository(dbConn)
+	taskRepo := postgresql.NewTaskRepository(dbConn, cfg.Webhook.Retry.MaxAttempts)
+	scheduleRepo := postgresql.NewScheduleRepository(dbConn)
+	eventBus := postgresql.NewTaskEventBus(dbConn, cfg.DB.GetDSN())
+	webhookRepo := postgresql.NewWebhookRepository(dbConn)
+	workflowRepo := postgresql.NewWorkflowRepository(dbConn)
 
-	processTask := func(ctx context.Context) (json.RawMessage, error) {
+	generateReport := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
 		logger.Info("Starting long running task")
-		time.Sleep(3 * time.Minute)
+
+		const steps = 10
+		for step := 1; step <= steps; step++ {
+			select {
+			case <-time.After(3 * time.Minute / steps):
+			case <-ctx.Done():
+				logger.Info("Long running task interrupted", zap.Error(context.Cause(ctx)))
+				return nil, ctx.Err()
+			}
+
+			message := fmt.Sprintf("Processed %d of %d parts", step, steps)
+			if err := usecase.ReportProgress(ctx, step*100/steps, message); err != nil {
+				logger.Warn("Failed to report progress", zap.Error(err))
+			}
+		}
 
 		result := map[string]interface{}{
 			"message":   "Task completed successfully",
@@ -66,8 +85,46 @@ func main() {
 		return resultJSON, nil
 	}
 
-	taskUseCase := usecase.NewTaskUseCase(taskRepo, processTask)
-	uc := usecase.NewUseCase(taskUseCase)
+	sendEmail := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		var input struct {
+			To string `json:"to"`
+		}
+		if len(payload) > 0 {
+			if err := json.Unmarshal(payload, &input); err != nil {
+				return nil, fmt.Errorf("invalid payload: %w", err)
+			}
+		}
+
+		select {
+		case <-time.After(5 * time.Second):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		result := map[string]interface{}{
+			"to":      input.To,
+			"sent_at": time.Now().Format(time.RFC3339),
+		}
+
+		return json.Marshal(result)
+	}
+
+	handlers := usecase.NewTaskHandlerRegistry()
+	if err := handlers.Register("report.generate", generateReport); err != nil {
+		logger.Fatal("Failed to register task handler", zap.Error(err))
+	}
+	if err := handlers.Register("email.send", sendEmail); err != nil {
+		logger.Fatal("Failed to register task handler", zap.Error(err))
+	}
+
+	webhookUseCase := usecase.NewWebhookUseCase(webhookRepo, taskRepo, cfg.Webhook)
+	webhookUseCase.Start(context.Background())
+	taskUseCase := usecase.NewTaskUseCase(taskRepo, eventBus, webhookUseCase, handlers, cfg.Worker, usecase.NewRetryPolicy(cfg.Retry))
+	taskUseCase.Start(context.Background())
+	scheduleUseCase := usecase.NewScheduleUseCase(scheduleRepo, taskUseCase, cfg.Worker.SchedulerInterval)
+	scheduleUseCase.Start(context.Background())
+	workflowUseCase := usecase.NewWorkflowUseCase(workflowRepo, taskUseCase)
+	uc := usecase.NewUseCase(taskUseCase, scheduleUseCase, webhookUseCase, workflowUseCase)
 
 	server := http.NewServer(cfg, uc)
 
@@ -87,8 +144,21 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	// Воркеры останавливаем и при ошибке остановки сервера, чтобы выполняемые задачи вернулись в очередь
 	if err := server.Shutdown(ctx); err != nil {
-		logger.Fatal("Server shutdown error", zap.Error(err))
+		logger.Error("Server shutdown error", zap.Error(err))
+	}
+
+	if err := scheduleUseCase.Stop(ctx); err != nil {
+		logger.Error("Scheduler did not stop in time", zap.Error(err))
+	}
+
+	if err := taskUseCase.Stop(ctx); err != nil {
+		logger.Error("Workers did not stop in time", zap.Error(err))
+	}
+
+	if err := webhookUseCase.Stop(ctx); err != nil {
+		logger.Error("Webhook dispatcher did not stop in time", zap.Error(err))
 	}
 
 	logger.Info("Server exited properly")
@@ -3,14 +3,21 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Egorpalan/workmate-test/pkg/logger"
 	"github.com/joho/godotenv"
+	"go.uber.org/zap"
 )
 
 type Config struct {
-	DB     DBConfig
-	Server ServerConfig
+	DB      DBConfig
+	Server  ServerConfig
+	Worker  WorkerConfig
+	Retry   RetryConfig
+	Webhook WebhookConfig
 }
 
 type DBConfig struct {
@@ -23,7 +30,55 @@ type DBConfig struct {
 }
 
 type ServerConfig struct {
-	Port string
+	Port         string
+	MaxBodyBytes int64
+}
+
+type WorkerConfig struct {
+	ID                string
+	Concurrency       int
+	QueueSize         int
+	PollInterval      time.Duration
+	LeaseDuration     time.Duration
+	ReapInterval      time.Duration
+	TaskTimeout       time.Duration
+	SchedulerInterval time.Duration
+	PriorityAging     time.Duration
+	// IdempotencyWindow — сколько времени повтор запроса с тем же Idempotency-Key возвращает уже созданную задачу
+	IdempotencyWindow time.Duration
+	Queues            []QueueConfig
+}
+
+// DefaultQueue — очередь, в которую попадают задачи без явно указанной очереди
+const DefaultQueue = "default"
+
+// QueueConfig описывает именованную очередь задач.
+// Workers — число воркеров очереди на одной реплике, MaxInFlight — лимит одновременно
+// выполняемых задач очереди на всех репликах (0 — без лимита).
+type QueueConfig struct {
+	Name        string
+	Workers     int
+	MaxInFlight int
+}
+
+type RetryConfig struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// Jitter — доля случайного отклонения задержки, от 0 до 1
+	Jitter float64
+}
+
+// WebhookConfig описывает доставку вебхуков о завершении задач.
+// Secret подписывает тело запроса HMAC-SHA256; без него задачи с callback_url не принимаются.
+// AllowPrivateTargets разрешает отправку на loopback, link-local и частные адреса, например в локальной разработке.
+// Повторы доставки используют ту же схему задержек, что и задачи.
+type WebhookConfig struct {
+	Secret              string
+	Timeout             time.Duration
+	DispatchInterval    time.Duration
+	AllowPrivateTargets bool
+	Retry               RetryConfig
 }
 
 func LoadConfig() (*Config, error) {
@@ -42,12 +97,56 @@ func LoadConfig() (*Config, error) {
 	}
 
 	serverConfig := ServerConfig{
-		Port: getEnv("SERVER_PORT", "8080"),
+		Port:         getEnv("SERVER_PORT", "8080"),
+		MaxBodyBytes: int64(getEnvInt("SERVER_MAX_BODY_BYTES", 1<<20)),
+	}
+
+	concurrency := getEnvInt("WORKER_CONCURRENCY", 4)
+	queues, err := parseQueues(getEnv("WORKER_QUEUES", "default,critical,reports:1:2"), concurrency)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WORKER_QUEUES: %w", err)
+	}
+
+	workerConfig := WorkerConfig{
+		ID:                getEnv("WORKER_ID", defaultWorkerID()),
+		Concurrency:       concurrency,
+		QueueSize:         getEnvInt("WORKER_QUEUE_SIZE", 100),
+		PollInterval:      getEnvDuration("WORKER_POLL_INTERVAL", time.Second),
+		LeaseDuration:     getEnvDuration("WORKER_LEASE_DURATION", 30*time.Second),
+		ReapInterval:      getEnvDuration("WORKER_REAP_INTERVAL", 15*time.Second),
+		TaskTimeout:       getEnvDuration("WORKER_TASK_TIMEOUT", 10*time.Minute),
+		SchedulerInterval: getEnvDuration("WORKER_SCHEDULER_INTERVAL", time.Second),
+		PriorityAging:     getEnvDuration("WORKER_PRIORITY_AGING", 30*time.Second),
+		IdempotencyWindow: getEnvDuration("WORKER_IDEMPOTENCY_WINDOW", 24*time.Hour),
+		Queues:            queues,
+	}
+
+	retryConfig := RetryConfig{
+		MaxAttempts: getEnvInt("RETRY_MAX_ATTEMPTS", 3),
+		BaseDelay:   getEnvDuration("RETRY_BASE_DELAY", 5*time.Second),
+		MaxDelay:    getEnvDuration("RETRY_MAX_DELAY", 5*time.Minute),
+		Jitter:      getEnvFloat("RETRY_JITTER", 0.2),
+	}
+
+	webhookConfig := WebhookConfig{
+		Secret:              getEnv("WEBHOOK_SECRET", ""),
+		Timeout:             getEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		DispatchInterval:    getEnvDuration("WEBHOOK_DISPATCH_INTERVAL", time.Second),
+		AllowPrivateTargets: getEnvBool("WEBHOOK_ALLOW_PRIVATE_TARGETS", false),
+		Retry: RetryConfig{
+			MaxAttempts: getEnvInt("WEBHOOK_MAX_ATTEMPTS", 5),
+			BaseDelay:   getEnvDuration("WEBHOOK_BASE_DELAY", 10*time.Second),
+			MaxDelay:    getEnvDuration("WEBHOOK_MAX_DELAY", 10*time.Minute),
+			Jitter:      retryConfig.Jitter,
+		},
 	}
 
 	return &Config{
-		DB:     dbConfig,
-		Server: serverConfig,
+		DB:      dbConfig,
+		Server:  serverConfig,
+		Worker:  workerConfig,
+		Retry:   retryConfig,
+		Webhook: webhookConfig,
 	}, nil
 }
 
@@ -60,6 +159,127 @@ func getEnv(key, defaultValue string) string {
 	return value
 }
 
+// getEnvInt получает целое положительное значение из переменной окружения или возвращает значение по умолчанию
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed <= 0 {
+		logger.Warn("Invalid integer in environment variable, using default",
+			zap.String("key", key), zap.String("value", value), zap.Int("default", defaultValue))
+		return defaultValue
+	}
+	return parsed
+}
+
+// getEnvFloat получает неотрицательное дробное значение из переменной окружения или возвращает значение по умолчанию
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil || parsed < 0 {
+		logger.Warn("Invalid number in environment variable, using default",
+			zap.String("key", key), zap.String("value", value), zap.Float64("default", defaultValue))
+		return defaultValue
+	}
+	return parsed
+}
+
+// getEnvBool получает логическое значение (true/false, 1/0) из переменной окружения или возвращает значение по умолчанию
+func getEnvBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		logger.Warn("Invalid boolean in environment variable, using default",
+			zap.String("key", key), zap.String("value", value), zap.Bool("default", defaultValue))
+		return defaultValue
+	}
+	return parsed
+}
+
+// getEnvDuration получает положительную длительность (например, "500ms" или "1m") из переменной окружения
+// или возвращает значение по умолчанию
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed <= 0 {
+		logger.Warn("Invalid duration in environment variable, using default",
+			zap.String("key", key), zap.String("value", value), zap.Duration("default", defaultValue))
+		return defaultValue
+	}
+	return parsed
+}
+
+// parseQueues разбирает список очередей вида "name[:workers[:max_in_flight]]" через запятую.
+// Для очередей без явного числа воркеров используется defaultWorkers. Очередь default обязательна.
+func parseQueues(value string, defaultWorkers int) ([]QueueConfig, error) {
+	queues := make([]QueueConfig, 0)
+	seen := make(map[string]bool)
+
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		parts := strings.Split(item, ":")
+		if len(parts) > 3 || parts[0] == "" {
+			return nil, fmt.Errorf("malformed queue %q", item)
+		}
+
+		queue := QueueConfig{Name: parts[0], Workers: defaultWorkers}
+		if len(parts) > 1 {
+			workers, err := strconv.Atoi(parts[1])
+			if err != nil || workers <= 0 {
+				return nil, fmt.Errorf("queue %q: workers must be a positive integer", queue.Name)
+			}
+			queue.Workers = workers
+		}
+		if len(parts) > 2 {
+			maxInFlight, err := strconv.Atoi(parts[2])
+			if err != nil || maxInFlight < 0 {
+				return nil, fmt.Errorf("queue %q: max in-flight must be a non-negative integer", queue.Name)
+			}
+			queue.MaxInFlight = maxInFlight
+		}
+
+		if seen[queue.Name] {
+			return nil, fmt.Errorf("duplicate queue %q", queue.Name)
+		}
+		seen[queue.Name] = true
+		queues = append(queues, queue)
+	}
+
+	if !seen[DefaultQueue] {
+		return nil, fmt.Errorf("queue %q is required", DefaultQueue)
+	}
+
+	return queues, nil
+}
+
+// defaultWorkerID возвращает идентификатор реплики на основе имени хоста и PID процесса
+func defaultWorkerID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "worker"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
 // GetDSN возвращает строку подключения к базе данных
 func (c *DBConfig) GetDSN() string {
 	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
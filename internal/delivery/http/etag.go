@@ -0,0 +1,89 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Egorpalan/workmate-test/internal/entity"
+)
+
+// taskETag возвращает ETag задачи — ее версию в кавычках
+func taskETag(task *entity.Task) string {
+	return strconv.Quote(strconv.Itoa(task.Version))
+}
+
+// respondWithTask отправляет задачу клиенту вместе с ее ETag
+func respondWithTask(w http.ResponseWriter, code int, task *entity.Task) {
+	w.Header().Set("ETag", taskETag(task))
+	respondWithJSON(w, code, task)
+}
+
+// etagMatches сообщает, совпадает ли etag с одним из ETag в заголовке If-None-Match.
+// Сравнение слабое, как требует RFC 9110 для If-None-Match; "*" совпадает с любым ETag.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// parseIfMatch возвращает версии задачи из сильных ETag списка в заголовке If-Match и false,
+// если условия нет: заголовок пуст или содержит "*". Сравнение для If-Match сильное (RFC 9110),
+// поэтому слабые и некорректные ETag не совпадают ни с одной версией и в список не попадают.
+func parseIfMatch(header string) ([]int, bool) {
+	if strings.TrimSpace(header) == "" {
+		return nil, false
+	}
+
+	var versions []int
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return nil, false
+		}
+
+		unquoted, ok := strings.CutPrefix(candidate, `"`)
+		if ok {
+			unquoted, ok = strings.CutSuffix(unquoted, `"`)
+		}
+		if !ok {
+			continue
+		}
+		version, err := strconv.Atoi(unquoted)
+		if err != nil || version <= 0 || strconv.Itoa(version) != unquoted {
+			continue
+		}
+		versions = append(versions, version)
+	}
+
+	return versions, true
+}
+
+// ifMatchVersion возвращает версию задачи id для compare-and-swap по заголовку If-Match: 0 — условия нет.
+// Для списка из нескольких ETag берется текущая версия задачи, если она в списке; сам compare-and-swap
+// гарантирует, что она не изменилась до записи. Если ни один ETag не совпал, возвращается entity.ErrConflict.
+func (h *Handler) ifMatchVersion(r *http.Request, id string) (int, error) {
+	versions, conditional := parseIfMatch(r.Header.Get("If-Match"))
+	if !conditional {
+		return 0, nil
+	}
+	if len(versions) == 1 {
+		return versions[0], nil
+	}
+
+	task, err := h.useCase.Task.GetTaskByID(r.Context(), id)
+	if err != nil {
+		return 0, err
+	}
+	if !slices.Contains(versions, task.Version) {
+		return 0, fmt.Errorf("%w: task version is %d", entity.ErrConflict, task.Version)
+	}
+
+	return task.Version, nil
+}
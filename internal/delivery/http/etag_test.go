@@ -0,0 +1,245 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Egorpalan/workmate-test/config"
+	"github.com/Egorpalan/workmate-test/internal/entity"
+	"github.com/Egorpalan/workmate-test/internal/usecase"
+	"github.com/Egorpalan/workmate-test/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMain(m *testing.M) {
+	logger.Setup()
+	code := m.Run()
+	os.Exit(code)
+}
+
+type MockTaskUseCase struct {
+	mock.Mock
+}
+
+func (m *MockTaskUseCase) CreateTask(ctx context.Context, spec entity.TaskSpec) (*entity.Task, bool, error) {
+	args := m.Called(ctx, spec)
+	if args.Get(0) == nil {
+		return nil, args.Bool(1), args.Error(2)
+	}
+	return args.Get(0).(*entity.Task), args.Bool(1), args.Error(2)
+}
+
+func (m *MockTaskUseCase) CreateTasks(ctx context.Context, specs []entity.TaskSpec) (*entity.TaskBatch, error) {
+	args := m.Called(ctx, specs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.TaskBatch), args.Error(1)
+}
+
+func (m *MockTaskUseCase) GetTaskGraph(ctx context.Context, id string) (*entity.TaskGraph, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.TaskGraph), args.Error(1)
+}
+
+func (m *MockTaskUseCase) GetTaskChildren(ctx context.Context, id string, limit, offset int) (*entity.TaskChildren, error) {
+	args := m.Called(ctx, id, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.TaskChildren), args.Error(1)
+}
+
+func (m *MockTaskUseCase) GetTaskByID(ctx context.Context, id string) (*entity.Task, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Task), args.Error(1)
+}
+
+func (m *MockTaskUseCase) WaitTask(ctx context.Context, id string, timeout time.Duration) (*entity.Task, error) {
+	args := m.Called(ctx, id, timeout)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Task), args.Error(1)
+}
+
+func (m *MockTaskUseCase) ListTasks(ctx context.Context, filter entity.TaskFilter) ([]*entity.Task, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*entity.Task), args.Error(1)
+}
+
+func (m *MockTaskUseCase) CancelTask(ctx context.Context, id string, version int) (*entity.Task, error) {
+	args := m.Called(ctx, id, version)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Task), args.Error(1)
+}
+
+func (m *MockTaskUseCase) RetryTask(ctx context.Context, id string, version int) (*entity.Task, error) {
+	args := m.Called(ctx, id, version)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Task), args.Error(1)
+}
+
+func (m *MockTaskUseCase) ListTaskTypes() []string {
+	args := m.Called()
+	return args.Get(0).([]string)
+}
+
+func (m *MockTaskUseCase) ListQueues(ctx context.Context) ([]*entity.QueueStats, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*entity.QueueStats), args.Error(1)
+}
+
+func (m *MockTaskUseCase) SubscribeTaskEvents(taskID string) (<-chan entity.TaskEvent, func()) {
+	args := m.Called(taskID)
+	return args.Get(0).(<-chan entity.TaskEvent), args.Get(1).(func())
+}
+
+// serveTaskRequest выполняет запрос к роутеру с моком сценариев задач и заголовками headers
+func serveTaskRequest(taskUseCase *MockTaskUseCase, method, target string, headers map[string]string) *httptest.ResponseRecorder {
+	handler := NewHandler(usecase.NewUseCase(taskUseCase, nil, nil, nil), config.ServerConfig{MaxBodyBytes: 1 << 20})
+
+	req := httptest.NewRequest(method, target, nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	rec := httptest.NewRecorder()
+	setupRouter(handler).ServeHTTP(rec, req)
+
+	return rec
+}
+
+// TestGetTask_IfNoneMatch тестирует ответ 304 при совпадении ETag задачи с одним из ETag в If-None-Match
+// по слабому сравнению и полный ответ при несовпадении
+func TestGetTask_IfNoneMatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		wantCode    int
+	}{
+		{"no header", "", http.StatusOK},
+		{"same version", `"3"`, http.StatusNotModified},
+		{"weak tag", `W/"3"`, http.StatusNotModified},
+		{"list", `"1", "3"`, http.StatusNotModified},
+		{"any", `*`, http.StatusNotModified},
+		{"other version", `"2"`, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taskUseCase := new(MockTaskUseCase)
+			taskUseCase.On("WaitTask", mock.Anything, "task-id", time.Duration(0)).
+				Return(&entity.Task{ID: "task-id", Status: entity.TaskStatusPending, Version: 3}, nil)
+
+			rec := serveTaskRequest(taskUseCase, http.MethodGet, "/api/tasks/task-id",
+				map[string]string{"If-None-Match": tt.ifNoneMatch})
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
+			if tt.wantCode == http.StatusNotModified {
+				assert.Empty(t, rec.Body.String())
+			}
+		})
+	}
+}
+
+// TestCancelTask_IfMatch тестирует передачу версии из If-Match в compare-and-swap: список ETag сверяется
+// с текущей версией задачи, а слабые и некорректные ETag и несовпавшая версия дают 412
+func TestCancelTask_IfMatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		ifMatch     string
+		wantVersion int
+		wantCode    int
+	}{
+		{"no header", "", 0, http.StatusOK},
+		{"any", `*`, 0, http.StatusOK},
+		{"any in list", `"1", *`, 0, http.StatusOK},
+		{"single tag", `"3"`, 3, http.StatusOK},
+		{"list with current version", `"1", "3"`, 3, http.StatusOK},
+		{"list without current version", `"1", "2"`, -1, http.StatusPreconditionFailed},
+		{"weak tag", `W/"3"`, -1, http.StatusPreconditionFailed},
+		{"malformed tag", `3`, -1, http.StatusPreconditionFailed},
+		{"weak and malformed list", `W/"3", "abc", "+3"`, -1, http.StatusPreconditionFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taskUseCase := new(MockTaskUseCase)
+			taskUseCase.On("GetTaskByID", mock.Anything, "task-id").
+				Return(&entity.Task{ID: "task-id", Status: entity.TaskStatusPending, Version: 3}, nil)
+			taskUseCase.On("CancelTask", mock.Anything, "task-id", mock.Anything).
+				Return(&entity.Task{ID: "task-id", Status: entity.TaskStatusCancelled, Version: 4}, nil)
+
+			rec := serveTaskRequest(taskUseCase, http.MethodPost, "/api/tasks/task-id/cancel",
+				map[string]string{"If-Match": tt.ifMatch})
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantVersion < 0 {
+				taskUseCase.AssertNotCalled(t, "CancelTask", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			taskUseCase.AssertCalled(t, "CancelTask", mock.Anything, "task-id", tt.wantVersion)
+			assert.Equal(t, `"4"`, rec.Header().Get("ETag"))
+		})
+	}
+}
+
+// TestRetryTask_IfMatch тестирует ответы 412, если версия изменилась к моменту повтора,
+// и 404 для несуществующей задачи при списке ETag в If-Match
+func TestRetryTask_IfMatch(t *testing.T) {
+	t.Run("version changed", func(t *testing.T) {
+		taskUseCase := new(MockTaskUseCase)
+		taskUseCase.On("RetryTask", mock.Anything, "task-id", 3).
+			Return(nil, entity.ErrConflict)
+
+		rec := serveTaskRequest(taskUseCase, http.MethodPost, "/api/tasks/task-id/retry",
+			map[string]string{"If-Match": `"3"`})
+
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	})
+
+	t.Run("task not found", func(t *testing.T) {
+		taskUseCase := new(MockTaskUseCase)
+		taskUseCase.On("GetTaskByID", mock.Anything, "task-id").Return(nil, entity.ErrTaskNotFound)
+
+		rec := serveTaskRequest(taskUseCase, http.MethodPost, "/api/tasks/task-id/retry",
+			map[string]string{"If-Match": `"2", "3"`})
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		taskUseCase.AssertNotCalled(t, "RetryTask", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("accepted", func(t *testing.T) {
+		taskUseCase := new(MockTaskUseCase)
+		taskUseCase.On("RetryTask", mock.Anything, "task-id", 3).
+			Return(&entity.Task{ID: "task-id", Status: entity.TaskStatusPending, Version: 4}, nil)
+
+		rec := serveTaskRequest(taskUseCase, http.MethodPost, "/api/tasks/task-id/retry",
+			map[string]string{"If-Match": `"3"`})
+
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+		assert.Equal(t, `"4"`, rec.Header().Get("ETag"))
+	})
+}
@@ -0,0 +1,156 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Egorpalan/workmate-test/internal/entity"
+	"github.com/Egorpalan/workmate-test/pkg/logger"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// sseHeartbeatInterval — как часто в поток отправляется комментарий, чтобы прокси не закрывали соединение
+const sseHeartbeatInterval = 15 * time.Second
+
+// StreamTaskEvents передает по SSE изменения всех задач, пока клиент не отключится
+func (h *Handler) StreamTaskEvents(w http.ResponseWriter, r *http.Request) {
+	events, unsubscribe := h.useCase.Task.SubscribeTaskEvents("")
+	defer unsubscribe()
+
+	stream, ok := newEventStream(w)
+	if !ok {
+		return
+	}
+
+	h.streamEvents(r.Context(), stream, events, nil)
+}
+
+// StreamTaskEventsByID передает по SSE текущее состояние задачи и все его изменения,
+// пока задача не перейдет в конечный статус
+func (h *Handler) StreamTaskEventsByID(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		respondWithError(w, http.StatusBadRequest, "Task ID is required")
+		return
+	}
+
+	// Подписываемся до чтения задачи, чтобы не пропустить изменения между чтением и подпиской
+	events, unsubscribe := h.useCase.Task.SubscribeTaskEvents(id)
+	defer unsubscribe()
+
+	task, err := h.useCase.Task.GetTaskByID(r.Context(), id)
+	if errors.Is(err, entity.ErrTaskNotFound) {
+		respondWithError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to get task", zap.String("id", id), zap.Error(err))
+		respondWithError(w, http.StatusInternalServerError, "Failed to get task")
+		return
+	}
+
+	stream, ok := newEventStream(w)
+	if !ok {
+		return
+	}
+
+	snapshot := entity.NewTaskEvent(task)
+	if err := stream.send(snapshot); err != nil || snapshot.Status.IsTerminal() {
+		return
+	}
+
+	h.streamEvents(r.Context(), stream, events, func(event entity.TaskEvent) bool {
+		return event.Status.IsTerminal()
+	})
+}
+
+// streamEvents пересылает события в поток, пока клиент не отключится, сервер не начнет остановку
+// или stop не вернет true для очередного события
+func (h *Handler) streamEvents(
+	ctx context.Context,
+	stream *eventStream,
+	events <-chan entity.TaskEvent,
+	stop func(entity.TaskEvent) bool,
+) {
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-h.streams.Done():
+			return
+		case <-heartbeat.C:
+			if err := stream.ping(); err != nil {
+				return
+			}
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := stream.send(event); err != nil {
+				return
+			}
+			if stop != nil && stop(event) {
+				return
+			}
+		}
+	}
+}
+
+// eventStream записывает события в ответ в формате Server-Sent Events
+type eventStream struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+// newEventStream отправляет заголовки SSE и снимает для ответа WriteTimeout сервера.
+// При ошибке отправляет клиенту 500 и возвращает false.
+func newEventStream(w http.ResponseWriter) (*eventStream, bool) {
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		logger.Error("Failed to disable write deadline for event stream", zap.Error(err))
+		respondWithError(w, http.StatusInternalServerError, "Streaming is not supported")
+		return nil, false
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if err := rc.Flush(); err != nil {
+		logger.Error("Failed to flush event stream", zap.Error(err))
+		return nil, false
+	}
+
+	return &eventStream{w: w, rc: rc}, true
+}
+
+// send отправляет клиенту событие задачи
+func (s *eventStream) send(event entity.TaskEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task event: %w", err)
+	}
+
+	if _, err := fmt.Fprintf(s.w, "event: task\ndata: %s\n\n", data); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// ping отправляет клиенту комментарий, поддерживающий соединение
+func (s *eventStream) ping() error {
+	if _, err := fmt.Fprint(s.w, ": ping\n\n"); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
@@ -0,0 +1,150 @@
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Egorpalan/workmate-test/config"
+	"github.com/Egorpalan/workmate-test/internal/entity"
+	"github.com/Egorpalan/workmate-test/internal/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// streamTaskEvents открывает SSE-поток target на тестовом сервере и читает его до закрытия сервером.
+// Возвращает код ответа и статусы полученных событий.
+func streamTaskEvents(t *testing.T, handler *Handler, target string) (int, []entity.TaskStatus) {
+	t.Helper()
+
+	server := httptest.NewServer(setupRouter(handler))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + target)
+	if err != nil {
+		t.Fatalf("failed to open event stream: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read event stream: %v", err)
+	}
+
+	var statuses []entity.TaskStatus
+	for _, line := range strings.Split(string(body), "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok || resp.StatusCode != http.StatusOK {
+			continue
+		}
+		var event entity.TaskEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			t.Fatalf("failed to decode event %q: %v", data, err)
+		}
+		statuses = append(statuses, event.Status)
+	}
+
+	return resp.StatusCode, statuses
+}
+
+// taskEvents возвращает закрытый канал с событиями задачи task-id в статусах statuses
+func taskEvents(statuses ...entity.TaskStatus) <-chan entity.TaskEvent {
+	events := make(chan entity.TaskEvent, len(statuses))
+	for _, status := range statuses {
+		events <- entity.TaskEvent{TaskID: "task-id", Status: status}
+	}
+	close(events)
+	return events
+}
+
+// TestStreamTaskEventsByID тестирует поток событий задачи: 404 для несуществующей задачи, одно событие
+// для уже завершенной и пересылку изменений до перехода в конечный статус
+func TestStreamTaskEventsByID(t *testing.T) {
+	tests := []struct {
+		name         string
+		task         *entity.Task
+		err          error
+		events       []entity.TaskStatus
+		wantCode     int
+		wantStatuses []entity.TaskStatus
+	}{
+		{
+			name:     "not found",
+			err:      entity.ErrTaskNotFound,
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:         "finished task",
+			task:         &entity.Task{ID: "task-id", Status: entity.TaskStatusCompleted},
+			events:       []entity.TaskStatus{entity.TaskStatusCompleted},
+			wantCode:     http.StatusOK,
+			wantStatuses: []entity.TaskStatus{entity.TaskStatusCompleted},
+		},
+		{
+			name:     "until terminal status",
+			task:     &entity.Task{ID: "task-id", Status: entity.TaskStatusPending},
+			events:   []entity.TaskStatus{entity.TaskStatusProcessing, entity.TaskStatusFailed, entity.TaskStatusPending},
+			wantCode: http.StatusOK,
+			wantStatuses: []entity.TaskStatus{
+				entity.TaskStatusPending, entity.TaskStatusProcessing, entity.TaskStatusFailed,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taskUseCase := new(MockTaskUseCase)
+			taskUseCase.On("SubscribeTaskEvents", "task-id").Return(taskEvents(tt.events...), func() {})
+			if tt.err != nil {
+				taskUseCase.On("GetTaskByID", mock.Anything, "task-id").Return(nil, tt.err)
+			} else {
+				taskUseCase.On("GetTaskByID", mock.Anything, "task-id").Return(tt.task, nil)
+			}
+
+			handler := NewHandler(usecase.NewUseCase(taskUseCase, nil, nil, nil), config.ServerConfig{MaxBodyBytes: 1 << 20})
+			code, statuses := streamTaskEvents(t, handler, "/api/tasks/task-id/events")
+
+			assert.Equal(t, tt.wantCode, code)
+			assert.Equal(t, tt.wantStatuses, statuses)
+		})
+	}
+}
+
+// TestStreamTaskEvents тестирует пересылку событий всех задач до закрытия подписки и завершение потока
+// при остановке сервера
+func TestStreamTaskEvents(t *testing.T) {
+	t.Run("subscription closed", func(t *testing.T) {
+		taskUseCase := new(MockTaskUseCase)
+		taskUseCase.On("SubscribeTaskEvents", "").
+			Return(taskEvents(entity.TaskStatusPending, entity.TaskStatusCompleted), func() {})
+
+		handler := NewHandler(usecase.NewUseCase(taskUseCase, nil, nil, nil), config.ServerConfig{MaxBodyBytes: 1 << 20})
+		code, statuses := streamTaskEvents(t, handler, "/api/tasks/events")
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []entity.TaskStatus{entity.TaskStatusPending, entity.TaskStatusCompleted}, statuses)
+	})
+
+	t.Run("server shutdown", func(t *testing.T) {
+		unsubscribed := make(chan struct{})
+		taskUseCase := new(MockTaskUseCase)
+		taskUseCase.On("SubscribeTaskEvents", "").
+			Return((<-chan entity.TaskEvent)(make(chan entity.TaskEvent)), func() { close(unsubscribed) })
+
+		handler := NewHandler(usecase.NewUseCase(taskUseCase, nil, nil, nil), config.ServerConfig{MaxBodyBytes: 1 << 20})
+		handler.closeStreams()
+		code, statuses := streamTaskEvents(t, handler, "/api/tasks/events")
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Empty(t, statuses)
+		select {
+		case <-unsubscribed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("event stream did not unsubscribe on server shutdown")
+		}
+	})
+}
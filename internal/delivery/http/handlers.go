@@ -1,40 +1,142 @@
 package http
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/go-chi/chi/v5"
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/Egorpalan/workmate-test/config"
+	"github.com/Egorpalan/workmate-test/internal/entity"
 	"github.com/Egorpalan/workmate-test/internal/usecase"
 	"github.com/Egorpalan/workmate-test/pkg/logger"
 	"go.uber.org/zap"
 )
 
+const (
+	// maxTaskWait ограничивает ожидание в GET /api/tasks/{id}?wait= с запасом до таймаута запроса в 60 секунд
+	maxTaskWait = 50 * time.Second
+	// waitWriteMargin — запас ко времени ожидания при продлении срока записи ответа
+	waitWriteMargin = 5 * time.Second
+)
+
+const (
+	// idempotencyKeyHeader — заголовок с ключом идемпотентности запроса создания задачи
+	idempotencyKeyHeader = "Idempotency-Key"
+	// idempotentReplayedHeader отмечает ответ, повторно вернувший ранее созданную задачу
+	idempotentReplayedHeader = "Idempotent-Replayed"
+)
+
 type Handler struct {
-	useCase *usecase.UseCase
+	useCase      *usecase.UseCase
+	maxBodyBytes int64
+
+	// streams отменяется при остановке сервера, чтобы завершить открытые SSE-потоки и ожидания ?wait=
+	streams      context.Context
+	closeStreams context.CancelFunc
 }
 
 // NewHandler создает новый экземпляр Handler
-func NewHandler(useCase *usecase.UseCase) *Handler {
+func NewHandler(useCase *usecase.UseCase, cfg config.ServerConfig) *Handler {
+	streams, closeStreams := context.WithCancel(context.Background())
+
 	return &Handler{
-		useCase: useCase,
+		useCase:      useCase,
+		maxBodyBytes: cfg.MaxBodyBytes,
+		streams:      streams,
+		closeStreams: closeStreams,
 	}
 }
 
-// CreateTask создает новую задачу
+// CreateTask создает новую задачу указанного типа с входными данными из поля payload.
+// Повтор запроса с тем же заголовком Idempotency-Key или с unique_key незавершенной задачи
+// возвращает ранее созданную задачу со статусом 200.
 func (h *Handler) CreateTask(w http.ResponseWriter, r *http.Request) {
-	task, err := h.useCase.Task.CreateTask(r.Context())
+	var spec entity.TaskSpec
+	if !h.decodeBody(w, r, &spec) {
+		return
+	}
+	if spec.Type == "" {
+		respondWithError(w, http.StatusBadRequest, "Task type is required")
+		return
+	}
+	spec.IdempotencyKey = r.Header.Get(idempotencyKeyHeader)
+
+	task, created, err := h.useCase.Task.CreateTask(r.Context(), spec)
+	if errors.Is(err, usecase.ErrUnknownTaskType) {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown task type %q", spec.Type))
+		return
+	}
+	if errors.Is(err, usecase.ErrUnknownQueue) {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown queue %q", spec.Queue))
+		return
+	}
+	if errors.Is(err, usecase.ErrInvalidTaskSpec) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, usecase.ErrIdempotencyKeyReused) {
+		respondWithError(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used with a different request")
+		return
+	}
+	if errors.Is(err, usecase.ErrQueueFull) {
+		w.Header().Set("Retry-After", "5")
+		respondWithError(w, http.StatusServiceUnavailable, "Task queue is full, try again later")
+		return
+	}
 	if err != nil {
 		logger.Error("Failed to create task", zap.Error(err))
 		respondWithError(w, http.StatusInternalServerError, "Failed to create task")
 		return
 	}
 
-	respondWithJSON(w, http.StatusCreated, task)
+	if !created {
+		if spec.IdempotencyKey != "" {
+			w.Header().Set(idempotentReplayedHeader, "true")
+		}
+		respondWithTask(w, http.StatusOK, task)
+		return
+	}
+
+	respondWithTask(w, http.StatusCreated, task)
 }
 
-// GetTask возвращает задачу по ее ID
+// CreateTaskBatch создает пачку задач из массива параметров в теле запроса в одной транзакции
+// и возвращает ID пачки и ID созданных задач в порядке запроса
+func (h *Handler) CreateTaskBatch(w http.ResponseWriter, r *http.Request) {
+	var specs []entity.TaskSpec
+	if !h.decodeBody(w, r, &specs) {
+		return
+	}
+
+	batch, err := h.useCase.Task.CreateTasks(r.Context(), specs)
+	if errors.Is(err, usecase.ErrUnknownTaskType) || errors.Is(err, usecase.ErrUnknownQueue) ||
+		errors.Is(err, usecase.ErrInvalidTaskSpec) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, usecase.ErrQueueFull) {
+		w.Header().Set("Retry-After", "5")
+		respondWithError(w, http.StatusServiceUnavailable, "Task queue is full, try again later")
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to create task batch", zap.Error(err))
+		respondWithError(w, http.StatusInternalServerError, "Failed to create tasks")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, batch)
+}
+
+// GetTask возвращает задачу по ее ID с версией в заголовке ETag. С параметром wait (например, wait=30s или wait=30)
+// ждет перехода задачи в конечный статус, но не дольше maxTaskWait, и возвращает задачу в любом случае.
+// Если ETag совпадает с заголовком If-None-Match, отвечает 304 без тела.
 func (h *Handler) GetTask(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
@@ -42,18 +144,283 @@ func (h *Handler) GetTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	task, err := h.useCase.Task.GetTaskByID(r.Context(), id)
+	wait, err := parseWait(r.URL.Query().Get("wait"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if wait > 0 {
+		// Ответ отправляется позже WriteTimeout сервера, поэтому продлеваем срок записи для этого запроса
+		deadline := time.Now().Add(wait + waitWriteMargin)
+		if err := http.NewResponseController(w).SetWriteDeadline(deadline); err != nil {
+			logger.Warn("Failed to extend write deadline", zap.Error(err))
+		}
+	}
+
+	// При остановке сервера ожидание прерывается, и клиент получает текущее состояние задачи
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	stop := context.AfterFunc(h.streams, cancel)
+	defer stop()
+
+	task, err := h.useCase.Task.WaitTask(ctx, id, wait)
+	if errors.Is(err, entity.ErrTaskNotFound) {
+		respondWithError(w, http.StatusNotFound, "Task not found")
+		return
+	}
 	if err != nil {
 		logger.Error("Failed to get task", zap.String("id", id), zap.Error(err))
 		respondWithError(w, http.StatusInternalServerError, "Failed to get task")
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, task)
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" && etagMatches(ifNoneMatch, taskETag(task)) {
+		w.Header().Set("ETag", taskETag(task))
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	respondWithTask(w, http.StatusOK, task)
 }
 
-// ListTasks возвращает список задач с пагинацией
+// ListTasks возвращает список задач с пагинацией и фильтрами по статусу и пачке
 func (h *Handler) ListTasks(w http.ResponseWriter, r *http.Request) {
+	limit, offset := parsePagination(r)
+
+	status := entity.TaskStatus(r.URL.Query().Get("status"))
+	if status != "" && !status.IsValid() {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown task status %q", status))
+		return
+	}
+
+	batchID := r.URL.Query().Get("batch_id")
+	if batchID != "" && !isUUID(batchID) {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid batch_id %q", batchID))
+		return
+	}
+	parentID := r.URL.Query().Get("parent_id")
+	if parentID != "" && !isUUID(parentID) {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid parent_id %q", parentID))
+		return
+	}
+
+	filter := entity.TaskFilter{
+		Status:   status,
+		BatchID:  batchID,
+		ParentID: parentID,
+		Limit:    limit,
+		Offset:   offset,
+	}
+
+	tasks, err := h.useCase.Task.ListTasks(r.Context(), filter)
+	if err != nil {
+		logger.Error("Failed to list tasks", zap.Error(err))
+		respondWithError(w, http.StatusInternalServerError, "Failed to list tasks")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, tasks)
+}
+
+// CancelTask отменяет задачу. Ожидающая задача отменяется сразу (200),
+// для выполняемой запрос отмены передается воркеру (202).
+// С заголовком If-Match задача отменяется, только если ее версия совпадает с одним из ETag, иначе — 412.
+func (h *Handler) CancelTask(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		respondWithError(w, http.StatusBadRequest, "Task ID is required")
+		return
+	}
+	version, err := h.ifMatchVersion(r, id)
+	var task *entity.Task
+	if err == nil {
+		task, err = h.useCase.Task.CancelTask(r.Context(), id, version)
+	}
+	if errors.Is(err, entity.ErrTaskNotFound) {
+		respondWithError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+	if errors.Is(err, entity.ErrConflict) {
+		respondWithError(w, http.StatusPreconditionFailed, "Task was modified, fetch it again to get the current ETag")
+		return
+	}
+	if errors.Is(err, usecase.ErrTaskNotCancellable) {
+		respondWithError(w, http.StatusConflict, "Task is already finished")
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to cancel task", zap.String("id", id), zap.Error(err))
+		respondWithError(w, http.StatusInternalServerError, "Failed to cancel task")
+		return
+	}
+
+	if task.Status == entity.TaskStatusCancelled {
+		respondWithTask(w, http.StatusOK, task)
+		return
+	}
+	respondWithTask(w, http.StatusAccepted, task)
+}
+
+// RetryTask повторно ставит в очередь задачу в статусе failed или dead.
+// Заголовок If-Match обрабатывается так же, как в CancelTask.
+func (h *Handler) RetryTask(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		respondWithError(w, http.StatusBadRequest, "Task ID is required")
+		return
+	}
+	version, err := h.ifMatchVersion(r, id)
+	var task *entity.Task
+	if err == nil {
+		task, err = h.useCase.Task.RetryTask(r.Context(), id, version)
+	}
+	if errors.Is(err, entity.ErrTaskNotFound) {
+		respondWithError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+	if errors.Is(err, entity.ErrConflict) {
+		respondWithError(w, http.StatusPreconditionFailed, "Task was modified, fetch it again to get the current ETag")
+		return
+	}
+	if errors.Is(err, usecase.ErrTaskNotRetryable) {
+		respondWithError(w, http.StatusConflict, "Only failed or dead tasks can be retried")
+		return
+	}
+	if errors.Is(err, entity.ErrDuplicateTask) {
+		respondWithError(w, http.StatusConflict, "An unfinished task with the same unique key already exists")
+		return
+	}
+	if errors.Is(err, entity.ErrInvalidTransition) {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if errors.Is(err, usecase.ErrQueueFull) {
+		w.Header().Set("Retry-After", "5")
+		respondWithError(w, http.StatusServiceUnavailable, "Task queue is full, try again later")
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to retry task", zap.String("id", id), zap.Error(err))
+		respondWithError(w, http.StatusInternalServerError, "Failed to retry task")
+		return
+	}
+
+	respondWithTask(w, http.StatusAccepted, task)
+}
+
+// GetTaskGraph возвращает граф зависимостей, в который входит задача
+func (h *Handler) GetTaskGraph(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		respondWithError(w, http.StatusBadRequest, "Task ID is required")
+		return
+	}
+
+	graph, err := h.useCase.Task.GetTaskGraph(r.Context(), id)
+	if errors.Is(err, entity.ErrTaskNotFound) {
+		respondWithError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to get task graph", zap.String("id", id), zap.Error(err))
+		respondWithError(w, http.StatusInternalServerError, "Failed to get task graph")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, graph)
+}
+
+// GetTaskChildren возвращает число дочерних задач в каждом статусе и их список с пагинацией
+func (h *Handler) GetTaskChildren(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		respondWithError(w, http.StatusBadRequest, "Task ID is required")
+		return
+	}
+	limit, offset := parsePagination(r)
+
+	children, err := h.useCase.Task.GetTaskChildren(r.Context(), id, limit, offset)
+	if errors.Is(err, entity.ErrTaskNotFound) {
+		respondWithError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to get child tasks", zap.String("id", id), zap.Error(err))
+		respondWithError(w, http.StatusInternalServerError, "Failed to get child tasks")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, children)
+}
+
+// ListTaskWebhooks возвращает доставки вебхуков задачи
+func (h *Handler) ListTaskWebhooks(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		respondWithError(w, http.StatusBadRequest, "Task ID is required")
+		return
+	}
+
+	deliveries, err := h.useCase.Webhook.ListDeliveries(r.Context(), id)
+	if errors.Is(err, entity.ErrTaskNotFound) {
+		respondWithError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to list task webhooks", zap.String("id", id), zap.Error(err))
+		respondWithError(w, http.StatusInternalServerError, "Failed to list task webhooks")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, deliveries)
+}
+
+// ListTaskTypes возвращает список зарегистрированных типов задач
+func (h *Handler) ListTaskTypes(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, h.useCase.Task.ListTaskTypes())
+}
+
+// parseWait разбирает время ожидания из параметра wait: длительность ("30s") или число секунд ("30").
+// Значение ограничивается maxTaskWait, пустой параметр означает ответ без ожидания.
+func parseWait(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	wait, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, convErr := strconv.Atoi(value)
+		if convErr != nil {
+			return 0, fmt.Errorf("invalid wait %q: expected a duration like 30s", value)
+		}
+		wait = time.Duration(seconds) * time.Second
+	}
+	if wait < 0 {
+		return 0, fmt.Errorf("invalid wait %q: must not be negative", value)
+	}
+
+	return min(wait, maxTaskWait), nil
+}
+
+// isUUID проверяет, что строка — UUID в каноническом виде xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx
+func isUUID(value string) bool {
+	if len(value) != 36 {
+		return false
+	}
+	for i, c := range value {
+		switch {
+		case i == 8 || i == 13 || i == 18 || i == 23:
+			if c != '-' {
+				return false
+			}
+		case !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'):
+			return false
+		}
+	}
+	return true
+}
+
+// parsePagination читает параметры limit и offset из строки запроса; некорректные значения заменяются значениями по умолчанию
+func parsePagination(r *http.Request) (int, int) {
 	limitStr := r.URL.Query().Get("limit")
 	offsetStr := r.URL.Query().Get("offset")
 
@@ -73,14 +440,45 @@ func (h *Handler) ListTasks(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	tasks, err := h.useCase.Task.ListTasks(r.Context(), limit, offset)
+	return limit, offset
+}
+
+// ListQueues возвращает состояние именованных очередей
+func (h *Handler) ListQueues(w http.ResponseWriter, r *http.Request) {
+	queues, err := h.useCase.Task.ListQueues(r.Context())
 	if err != nil {
-		logger.Error("Failed to list tasks", zap.Error(err))
-		respondWithError(w, http.StatusInternalServerError, "Failed to list tasks")
+		logger.Error("Failed to list queues", zap.Error(err))
+		respondWithError(w, http.StatusInternalServerError, "Failed to list queues")
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, tasks)
+	respondWithJSON(w, http.StatusOK, queues)
+}
+
+// decodeBody разбирает JSON-тело запроса размером не больше maxBodyBytes в dst; пустое тело не считается ошибкой.
+// При ошибке отправляет клиенту 400 или 413 и возвращает false.
+func (h *Handler) decodeBody(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	if h.maxBodyBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, h.maxBodyBytes)
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(dst)
+	if err == nil || errors.Is(err, io.EOF) {
+		return true
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		respondWithError(w, http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("Request body must not exceed %d bytes", maxBytesErr.Limit))
+		return false
+	}
+
+	respondWithError(w, http.StatusBadRequest, "Invalid request body")
+	return false
 }
 
 // respondWithJSON отправляет JSON-ответ клиенту
@@ -0,0 +1,292 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Egorpalan/workmate-test/config"
+	"github.com/Egorpalan/workmate-test/internal/entity"
+	"github.com/Egorpalan/workmate-test/internal/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// TestGetTask_WaitInterruptedByShutdown тестирует, что остановка сервера прерывает ожидание ?wait=
+// и клиент сразу получает текущее состояние задачи
+func TestGetTask_WaitInterruptedByShutdown(t *testing.T) {
+	taskUseCase := new(MockTaskUseCase)
+	taskUseCase.On("WaitTask", mock.Anything, "task-id", maxTaskWait).
+		Run(func(args mock.Arguments) {
+			<-args.Get(0).(context.Context).Done()
+		}).
+		Return(&entity.Task{ID: "task-id", Status: entity.TaskStatusProcessing, Version: 2}, nil)
+
+	handler := NewHandler(usecase.NewUseCase(taskUseCase, nil, nil, nil), config.ServerConfig{MaxBodyBytes: 1 << 20})
+	router := setupRouter(handler)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/tasks/task-id?wait=50s", nil))
+		done <- rec
+	}()
+
+	handler.closeStreams()
+
+	select {
+	case rec := <-done:
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait was not interrupted by server shutdown")
+	}
+}
+
+// TestParseWait тестирует разбор параметра wait: длительность или число секунд, ограничение maxTaskWait
+// и отказ в некорректных и отрицательных значениях
+func TestParseWait(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"empty", "", 0, false},
+		{"duration", "30s", 30 * time.Second, false},
+		{"milliseconds", "1500ms", 1500 * time.Millisecond, false},
+		{"seconds", "30", 30 * time.Second, false},
+		{"zero", "0", 0, false},
+		{"duration over cap", "5m", maxTaskWait, false},
+		{"seconds over cap", "3600", maxTaskWait, false},
+		{"negative duration", "-1s", 0, true},
+		{"negative seconds", "-5", 0, true},
+		{"unknown unit", "10y", 0, true},
+		{"not a number", "soon", 0, true},
+		{"fraction without unit", "1.5", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wait, err := parseWait(tt.value)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, wait)
+		})
+	}
+}
+
+// TestGetTask_Wait тестирует передачу ожидания из параметра wait в WaitTask и ответ 400 на некорректное значение
+func TestGetTask_Wait(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		wantWait time.Duration
+		wantCode int
+	}{
+		{"no wait", "", 0, http.StatusOK},
+		{"wait", "?wait=10s", 10 * time.Second, http.StatusOK},
+		{"capped wait", "?wait=600", maxTaskWait, http.StatusOK},
+		{"negative wait", "?wait=-1", -1, http.StatusBadRequest},
+		{"bad unit", "?wait=10parsecs", -1, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taskUseCase := new(MockTaskUseCase)
+			taskUseCase.On("WaitTask", mock.Anything, "task-id", mock.Anything).
+				Return(&entity.Task{ID: "task-id", Status: entity.TaskStatusCompleted, Version: 1}, nil)
+
+			rec := serveTaskRequest(taskUseCase, http.MethodGet, "/api/tasks/task-id"+tt.query, nil)
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantWait < 0 {
+				taskUseCase.AssertNotCalled(t, "WaitTask", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			taskUseCase.AssertCalled(t, "WaitTask", mock.Anything, "task-id", tt.wantWait)
+		})
+	}
+}
+
+// TestCreateTaskBatch тестирует ответы POST /api/tasks/batch: лимиты размера тела и числа задач, ошибки разбора,
+// ошибки отдельных задач пачки с их номером и успешное создание
+func TestCreateTaskBatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		batch       *entity.TaskBatch
+		err         error
+		wantCode    int
+		wantMessage string
+	}{
+		{
+			name:     "created",
+			body:     `[{"type":"test"},{"type":"test"}]`,
+			batch:    &entity.TaskBatch{ID: "batch-id", TaskIDs: []string{"task-1", "task-2"}},
+			wantCode: http.StatusCreated,
+		},
+		{
+			name:        "body too large",
+			body:        `[{"type":"test","payload":"` + strings.Repeat("x", 1<<16) + `"}]`,
+			wantCode:    http.StatusRequestEntityTooLarge,
+			wantMessage: "Request body must not exceed 65536 bytes",
+		},
+		{
+			name:        "not an array",
+			body:        `{"type":"test"}`,
+			wantCode:    http.StatusBadRequest,
+			wantMessage: "Invalid request body",
+		},
+		{
+			name:        "unknown field",
+			body:        `[{"type":"test","colour":"red"}]`,
+			wantCode:    http.StatusBadRequest,
+			wantMessage: "Invalid request body",
+		},
+		{
+			name: "empty batch",
+			body: `[]`,
+			err: fmt.Errorf("%w: batch must contain from 1 to %d tasks",
+				usecase.ErrInvalidTaskSpec, entity.MaxTaskBatchSize),
+			wantCode:    http.StatusBadRequest,
+			wantMessage: "invalid task spec: batch must contain from 1 to 1000 tasks",
+		},
+		{
+			name: "too many tasks",
+			body: "[" + strings.TrimSuffix(strings.Repeat(`{"type":"test"},`, entity.MaxTaskBatchSize+1), ",") + "]",
+			err: fmt.Errorf("%w: batch must contain from 1 to %d tasks",
+				usecase.ErrInvalidTaskSpec, entity.MaxTaskBatchSize),
+			wantCode:    http.StatusBadRequest,
+			wantMessage: "invalid task spec: batch must contain from 1 to 1000 tasks",
+		},
+		{
+			name:        "unknown type of one task",
+			body:        `[{"type":"test"},{"type":"nope"}]`,
+			err:         fmt.Errorf("task 1: %w: %q", usecase.ErrUnknownTaskType, "nope"),
+			wantCode:    http.StatusBadRequest,
+			wantMessage: `task 1: unknown task type: "nope"`,
+		},
+		{
+			name:        "unknown queue of one task",
+			body:        `[{"type":"test","queue":"nope"}]`,
+			err:         fmt.Errorf("task 0: %w: %q", usecase.ErrUnknownQueue, "nope"),
+			wantCode:    http.StatusBadRequest,
+			wantMessage: `task 0: unknown queue: "nope"`,
+		},
+		{
+			name:        "queue full",
+			body:        `[{"type":"test"}]`,
+			err:         usecase.ErrQueueFull,
+			wantCode:    http.StatusServiceUnavailable,
+			wantMessage: "Task queue is full, try again later",
+		},
+		{
+			name:        "repository error",
+			body:        `[{"type":"test"}]`,
+			err:         errors.New("connection refused"),
+			wantCode:    http.StatusInternalServerError,
+			wantMessage: "Failed to create tasks",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taskUseCase := new(MockTaskUseCase)
+			if tt.batch != nil {
+				taskUseCase.On("CreateTasks", mock.Anything, mock.Anything).Return(tt.batch, nil)
+			} else {
+				taskUseCase.On("CreateTasks", mock.Anything, mock.Anything).Return(nil, tt.err)
+			}
+
+			handler := NewHandler(usecase.NewUseCase(taskUseCase, nil, nil, nil), config.ServerConfig{MaxBodyBytes: 1 << 16})
+			req := httptest.NewRequest(http.MethodPost, "/api/tasks/batch", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			setupRouter(handler).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantMessage != "" {
+				assert.JSONEq(t, fmt.Sprintf(`{"error":%q}`, tt.wantMessage), rec.Body.String())
+			}
+			if tt.wantCode == http.StatusCreated {
+				assert.JSONEq(t, `{"batch_id":"batch-id","task_ids":["task-1","task-2"]}`, rec.Body.String())
+			}
+		})
+	}
+}
+
+// TestListTasks_Filters тестирует передачу фильтров в ListTasks и ответ 400 на неизвестный статус
+// и batch_id или parent_id, не являющиеся UUID
+func TestListTasks_Filters(t *testing.T) {
+	const id = "0b7a61c2-5f1e-4c7a-9d3b-2a6e8f4c1d90"
+
+	tests := []struct {
+		name        string
+		query       string
+		wantFilter  entity.TaskFilter
+		wantCode    int
+		wantMessage string
+	}{
+		{
+			name:       "no filters",
+			wantFilter: entity.TaskFilter{Limit: 10},
+			wantCode:   http.StatusOK,
+		},
+		{
+			name:       "all filters",
+			query:      "?status=pending&batch_id=" + id + "&parent_id=" + strings.ToUpper(id) + "&limit=5&offset=10",
+			wantFilter: entity.TaskFilter{Status: entity.TaskStatusPending, BatchID: id, ParentID: strings.ToUpper(id), Limit: 5, Offset: 10},
+			wantCode:   http.StatusOK,
+		},
+		{
+			name:        "unknown status",
+			query:       "?status=done",
+			wantCode:    http.StatusBadRequest,
+			wantMessage: `Unknown task status "done"`,
+		},
+		{
+			name:        "malformed batch_id",
+			query:       "?batch_id=foo",
+			wantCode:    http.StatusBadRequest,
+			wantMessage: `Invalid batch_id "foo"`,
+		},
+		{
+			name:        "batch_id without dashes",
+			query:       "?batch_id=" + strings.ReplaceAll(id, "-", ""),
+			wantCode:    http.StatusBadRequest,
+			wantMessage: `Invalid batch_id "0b7a61c25f1e4c7a9d3b2a6e8f4c1d90"`,
+		},
+		{
+			name:        "malformed parent_id",
+			query:       "?parent_id=" + id[:35] + "g",
+			wantCode:    http.StatusBadRequest,
+			wantMessage: `Invalid parent_id "0b7a61c2-5f1e-4c7a-9d3b-2a6e8f4c1d9g"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taskUseCase := new(MockTaskUseCase)
+			taskUseCase.On("ListTasks", mock.Anything, mock.Anything).Return([]*entity.Task{}, nil)
+
+			rec := serveTaskRequest(taskUseCase, http.MethodGet, "/api/tasks"+tt.query, nil)
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode != http.StatusOK {
+				assert.JSONEq(t, fmt.Sprintf(`{"error":%q}`, tt.wantMessage), rec.Body.String())
+				taskUseCase.AssertNotCalled(t, "ListTasks", mock.Anything, mock.Anything)
+				return
+			}
+			taskUseCase.AssertCalled(t, "ListTasks", mock.Anything, tt.wantFilter)
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Egorpalan/workmate-test/internal/entity"
+	"github.com/Egorpalan/workmate-test/internal/usecase"
+	"github.com/Egorpalan/workmate-test/pkg/logger"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// CreateSchedule создает расписание, по которому периодически создаются задачи
+func (h *Handler) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	var spec entity.ScheduleSpec
+	if !h.decodeBody(w, r, &spec) {
+		return
+	}
+	if !validateScheduleSpec(w, spec) {
+		return
+	}
+
+	schedule, err := h.useCase.Schedule.CreateSchedule(r.Context(), spec)
+	if respondWithScheduleSpecError(w, spec, err) {
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to create schedule", zap.Error(err))
+		respondWithError(w, http.StatusInternalServerError, "Failed to create schedule")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, schedule)
+}
+
+// GetSchedule возвращает расписание по его ID
+func (h *Handler) GetSchedule(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		respondWithError(w, http.StatusBadRequest, "Schedule ID is required")
+		return
+	}
+
+	schedule, err := h.useCase.Schedule.GetSchedule(r.Context(), id)
+	if errors.Is(err, entity.ErrScheduleNotFound) {
+		respondWithError(w, http.StatusNotFound, "Schedule not found")
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to get schedule", zap.String("id", id), zap.Error(err))
+		respondWithError(w, http.StatusInternalServerError, "Failed to get schedule")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, schedule)
+}
+
+// ListSchedules возвращает список расписаний с пагинацией
+func (h *Handler) ListSchedules(w http.ResponseWriter, r *http.Request) {
+	limit, offset := parsePagination(r)
+
+	schedules, err := h.useCase.Schedule.ListSchedules(r.Context(), limit, offset)
+	if err != nil {
+		logger.Error("Failed to list schedules", zap.Error(err))
+		respondWithError(w, http.StatusInternalServerError, "Failed to list schedules")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, schedules)
+}
+
+// UpdateSchedule полностью заменяет параметры расписания
+func (h *Handler) UpdateSchedule(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		respondWithError(w, http.StatusBadRequest, "Schedule ID is required")
+		return
+	}
+
+	var spec entity.ScheduleSpec
+	if !h.decodeBody(w, r, &spec) {
+		return
+	}
+	if !validateScheduleSpec(w, spec) {
+		return
+	}
+
+	schedule, err := h.useCase.Schedule.UpdateSchedule(r.Context(), id, spec)
+	if errors.Is(err, entity.ErrScheduleNotFound) {
+		respondWithError(w, http.StatusNotFound, "Schedule not found")
+		return
+	}
+	if respondWithScheduleSpecError(w, spec, err) {
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to update schedule", zap.String("id", id), zap.Error(err))
+		respondWithError(w, http.StatusInternalServerError, "Failed to update schedule")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, schedule)
+}
+
+// DeleteSchedule удаляет расписание; уже созданные по нему задачи не затрагиваются
+func (h *Handler) DeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		respondWithError(w, http.StatusBadRequest, "Schedule ID is required")
+		return
+	}
+
+	err := h.useCase.Schedule.DeleteSchedule(r.Context(), id)
+	if errors.Is(err, entity.ErrScheduleNotFound) {
+		respondWithError(w, http.StatusNotFound, "Schedule not found")
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to delete schedule", zap.String("id", id), zap.Error(err))
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete schedule")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validateScheduleSpec проверяет обязательные поля расписания и при ошибке отправляет клиенту 400
+func validateScheduleSpec(w http.ResponseWriter, spec entity.ScheduleSpec) bool {
+	if spec.CronExpression == "" {
+		respondWithError(w, http.StatusBadRequest, "Cron expression is required")
+		return false
+	}
+	if spec.TaskType == "" {
+		respondWithError(w, http.StatusBadRequest, "Task type is required")
+		return false
+	}
+	return true
+}
+
+// respondWithScheduleSpecError отправляет 400 для ошибок валидации расписания и возвращает true, если ответ отправлен
+func respondWithScheduleSpecError(w http.ResponseWriter, spec entity.ScheduleSpec, err error) bool {
+	if errors.Is(err, usecase.ErrUnknownTaskType) {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown task type %q", spec.TaskType))
+		return true
+	}
+	if errors.Is(err, usecase.ErrInvalidSchedule) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return true
+	}
+	return false
+}
@@ -21,7 +21,7 @@ type Server struct {
 
 // NewServer создает новый экземпляр Server
 func NewServer(cfg *config.Config, useCase *usecase.UseCase) *Server {
-	handler := NewHandler(useCase)
+	handler := NewHandler(useCase, cfg.Server)
 
 	return &Server{
 		httpServer: &http.Server{
@@ -44,14 +44,41 @@ func setupRouter(h *Handler) http.Handler {
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(60 * time.Second))
 
 	// Routes
 	r.Route("/api", func(r chi.Router) {
-		r.Route("/tasks", func(r chi.Router) {
-			r.Post("/", h.CreateTask)
-			r.Get("/{id}", h.GetTask)
-			r.Get("/", h.ListTasks)
+		// SSE-потоки живут дольше обычных запросов, поэтому не ограничиваются таймаутом
+		r.Get("/tasks/events", h.StreamTaskEvents)
+		r.Get("/tasks/{id}/events", h.StreamTaskEventsByID)
+
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.Timeout(60 * time.Second))
+
+			r.Route("/tasks", func(r chi.Router) {
+				r.Post("/", h.CreateTask)
+				r.Post("/batch", h.CreateTaskBatch)
+				r.Get("/{id}", h.GetTask)
+				r.Post("/{id}/cancel", h.CancelTask)
+				r.Post("/{id}/retry", h.RetryTask)
+				r.Get("/{id}/webhooks", h.ListTaskWebhooks)
+				r.Get("/{id}/graph", h.GetTaskGraph)
+				r.Get("/{id}/children", h.GetTaskChildren)
+				r.Get("/", h.ListTasks)
+			})
+			r.Get("/task-types", h.ListTaskTypes)
+			r.Get("/queues", h.ListQueues)
+			r.Route("/workflows", func(r chi.Router) {
+				r.Post("/", h.CreateWorkflow)
+				r.Get("/{id}", h.GetWorkflow)
+				r.Post("/{id}/cancel", h.CancelWorkflow)
+			})
+			r.Route("/schedules", func(r chi.Router) {
+				r.Post("/", h.CreateSchedule)
+				r.Get("/{id}", h.GetSchedule)
+				r.Put("/{id}", h.UpdateSchedule)
+				r.Delete("/{id}", h.DeleteSchedule)
+				r.Get("/", h.ListSchedules)
+			})
 		})
 	})
 
@@ -64,8 +91,9 @@ func (s *Server) Run() error {
 	return s.httpServer.ListenAndServe()
 }
 
-// Shutdown останавливает HTTP-сервер
+// Shutdown закрывает открытые SSE-потоки и останавливает HTTP-сервер
 func (s *Server) Shutdown(ctx context.Context) error {
 	logger.Info("Shutting down HTTP server")
+	s.handler.closeStreams()
 	return s.httpServer.Shutdown(ctx)
 }
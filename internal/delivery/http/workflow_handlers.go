@@ -0,0 +1,87 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Egorpalan/workmate-test/internal/entity"
+	"github.com/Egorpalan/workmate-test/internal/usecase"
+	"github.com/Egorpalan/workmate-test/pkg/logger"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// CreateWorkflow создает workflow из шагов в теле запроса и ставит в очередь его первые шаги
+func (h *Handler) CreateWorkflow(w http.ResponseWriter, r *http.Request) {
+	var spec entity.WorkflowSpec
+	if !h.decodeBody(w, r, &spec) {
+		return
+	}
+
+	workflow, err := h.useCase.Workflow.CreateWorkflow(r.Context(), spec)
+	if errors.Is(err, usecase.ErrUnknownTaskType) || errors.Is(err, usecase.ErrUnknownQueue) ||
+		errors.Is(err, usecase.ErrInvalidTaskSpec) || errors.Is(err, usecase.ErrInvalidWorkflowSpec) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, usecase.ErrQueueFull) {
+		w.Header().Set("Retry-After", "5")
+		respondWithError(w, http.StatusServiceUnavailable, "Task queue is full, try again later")
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to create workflow", zap.Error(err))
+		respondWithError(w, http.StatusInternalServerError, "Failed to create workflow")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, workflow)
+}
+
+// GetWorkflow возвращает workflow по его ID со статусом и задачами шагов
+func (h *Handler) GetWorkflow(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		respondWithError(w, http.StatusBadRequest, "Workflow ID is required")
+		return
+	}
+
+	workflow, err := h.useCase.Workflow.GetWorkflow(r.Context(), id)
+	if errors.Is(err, entity.ErrWorkflowNotFound) {
+		respondWithError(w, http.StatusNotFound, "Workflow not found")
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to get workflow", zap.String("id", id), zap.Error(err))
+		respondWithError(w, http.StatusInternalServerError, "Failed to get workflow")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, workflow)
+}
+
+// CancelWorkflow отменяет все незавершенные шаги workflow
+func (h *Handler) CancelWorkflow(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		respondWithError(w, http.StatusBadRequest, "Workflow ID is required")
+		return
+	}
+
+	workflow, err := h.useCase.Workflow.CancelWorkflow(r.Context(), id)
+	if errors.Is(err, entity.ErrWorkflowNotFound) {
+		respondWithError(w, http.StatusNotFound, "Workflow not found")
+		return
+	}
+	if errors.Is(err, usecase.ErrWorkflowNotCancellable) {
+		respondWithError(w, http.StatusConflict, "Workflow is already finished")
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to cancel workflow", zap.String("id", id), zap.Error(err))
+		respondWithError(w, http.StatusInternalServerError, "Failed to cancel workflow")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, workflow)
+}
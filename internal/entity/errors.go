@@ -0,0 +1,37 @@
+package entity
+
+import "errors"
+
+// ErrTaskNotFound возвращается, когда задачи с указанным ID не существует
+var ErrTaskNotFound = errors.New("task not found")
+
+// ErrScheduleNotFound возвращается, когда расписания с указанным ID не существует
+var ErrScheduleNotFound = errors.New("schedule not found")
+
+// ErrTaskFinished возвращается, когда операция неприменима к задаче в конечном статусе
+var ErrTaskFinished = errors.New("task is already finished")
+
+// ErrNoPendingTasks возвращается, когда в очереди нет задач, готовых к выполнению
+var ErrNoPendingTasks = errors.New("no pending tasks")
+
+// ErrInvalidTransition возвращается при попытке перевести задачу в статус, недопустимый из ее текущего статуса,
+// в том числе когда статус задачи успели изменить конкурентно
+var ErrInvalidTransition = errors.New("invalid task status transition")
+
+// ErrConflict возвращается, когда версия задачи не совпадает с ожидаемой: задачу успели изменить конкурентно
+var ErrConflict = errors.New("task version conflict")
+
+// ErrUnexpectedStatus возвращается, когда задача находится в статусе, не допускающем операцию
+var ErrUnexpectedStatus = errors.New("task is not in an expected status")
+
+// ErrLeaseLost возвращается, когда аренда задачи истекла или перешла к другому воркеру
+var ErrLeaseLost = errors.New("task lease lost")
+
+// ErrDuplicateTask возвращается, когда незавершенная задача того же типа с тем же unique_key уже существует
+var ErrDuplicateTask = errors.New("an unfinished task with the same unique key already exists")
+
+// ErrDependencyNotFound возвращается, когда задачи, указанной в depends_on, не существует
+var ErrDependencyNotFound = errors.New("dependency task not found")
+
+// ErrWorkflowNotFound возвращается, когда workflow с указанным ID не существует
+var ErrWorkflowNotFound = errors.New("workflow not found")
@@ -0,0 +1,34 @@
+package entity
+
+import "time"
+
+// TaskEvent описывает изменение статуса или прогресса задачи.
+// Событие содержит только краткое состояние задачи, чтобы помещаться в уведомление Postgres NOTIFY.
+type TaskEvent struct {
+	TaskID          string     `json:"task_id"`
+	Type            string     `json:"type"`
+	Queue           string     `json:"queue"`
+	Status          TaskStatus `json:"status"`
+	Progress        int        `json:"progress"`
+	ProgressMessage string     `json:"progress_message,omitempty"`
+	Error           string     `json:"error,omitempty"`
+	ErrorCode       string     `json:"error_code,omitempty"`
+	Attempts        int        `json:"attempts"`
+	At              time.Time  `json:"at"`
+}
+
+// NewTaskEvent возвращает событие с текущим состоянием задачи
+func NewTaskEvent(task *Task) TaskEvent {
+	return TaskEvent{
+		TaskID:          task.ID,
+		Type:            task.Type,
+		Queue:           task.Queue,
+		Status:          task.Status,
+		Progress:        task.Progress,
+		ProgressMessage: task.ProgressMessage,
+		Error:           task.Error,
+		ErrorCode:       task.ErrorCode,
+		Attempts:        task.Attempts,
+		At:              time.Now(),
+	}
+}
@@ -0,0 +1,43 @@
+package entity
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ScheduleIdempotencyKeyPrefix — зарезервированный префикс ключей идемпотентности задач расписаний.
+// Клиенты не могут передать ключ с этим префиксом, поэтому их ключи не пересекаются с ключами расписаний.
+const ScheduleIdempotencyKeyPrefix = "schedule:"
+
+// ScheduleIdempotencyKey возвращает ключ идемпотентности задачи, созданной расписанием scheduleID
+// на срабатывание в runAt: реплики, одновременно обрабатывающие одно срабатывание, создают одну задачу
+func ScheduleIdempotencyKey(scheduleID string, runAt time.Time) string {
+	return fmt.Sprintf("%s%s:%d", ScheduleIdempotencyKeyPrefix, scheduleID, runAt.Unix())
+}
+
+// Schedule описывает периодическую задачу, которая создается по cron-выражению
+type Schedule struct {
+	ID             string          `json:"id" db:"id"`
+	Name           string          `json:"name" db:"name"`
+	CronExpression string          `json:"cron_expression" db:"cron_expression"`
+	TaskType       string          `json:"task_type" db:"task_type"`
+	Payload        json.RawMessage `json:"payload,omitempty" db:"payload"`
+	Timezone       string          `json:"timezone" db:"timezone"`
+	Enabled        bool            `json:"enabled" db:"enabled"`
+	NextRunAt      time.Time       `json:"next_run_at" db:"next_run_at"`
+	LastRunAt      *time.Time      `json:"last_run_at,omitempty" db:"last_run_at"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// ScheduleSpec описывает параметры создания и изменения расписания.
+// Enabled по умолчанию true, Timezone по умолчанию UTC.
+type ScheduleSpec struct {
+	Name           string          `json:"name"`
+	CronExpression string          `json:"cron_expression"`
+	TaskType       string          `json:"task_type"`
+	Payload        json.RawMessage `json:"payload,omitempty"`
+	Timezone       string          `json:"timezone,omitempty"`
+	Enabled        *bool           `json:"enabled,omitempty"`
+}
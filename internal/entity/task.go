@@ -1,24 +1,268 @@
 package entity
 
 import (
+	"database/sql/driver"
 	"encoding/json"
+	"fmt"
 	"time"
 )
 
 type TaskStatus string
 
 const (
+	TaskStatusBlocked    TaskStatus = "blocked"
+	TaskStatusScheduled  TaskStatus = "scheduled"
 	TaskStatusPending    TaskStatus = "pending"
 	TaskStatusProcessing TaskStatus = "processing"
 	TaskStatusCompleted  TaskStatus = "completed"
 	TaskStatusFailed     TaskStatus = "failed"
+	TaskStatusCancelled  TaskStatus = "cancelled"
+	TaskStatusDead       TaskStatus = "dead"
 )
 
+// IsValid сообщает, является ли значение одним из известных статусов задачи
+func (s TaskStatus) IsValid() bool {
+	switch s {
+	case TaskStatusBlocked, TaskStatusScheduled, TaskStatusPending, TaskStatusProcessing, TaskStatusCompleted,
+		TaskStatusFailed, TaskStatusCancelled, TaskStatusDead:
+		return true
+	}
+	return false
+}
+
+// IsTerminal сообщает, является ли статус конечным: задача в нем больше не изменится без ручного вмешательства
+func (s TaskStatus) IsTerminal() bool {
+	switch s {
+	case TaskStatusCompleted, TaskStatusFailed, TaskStatusCancelled, TaskStatusDead:
+		return true
+	}
+	return false
+}
+
+// taskTransitions перечисляет допустимые переходы между статусами задачи.
+// Из completed и cancelled переходов нет; failed и dead покидаются только ручным повтором.
+var taskTransitions = map[TaskStatus][]TaskStatus{
+	TaskStatusBlocked:   {TaskStatusPending, TaskStatusScheduled, TaskStatusCompleted, TaskStatusFailed, TaskStatusCancelled},
+	TaskStatusScheduled: {TaskStatusPending, TaskStatusCancelled},
+	TaskStatusPending:   {TaskStatusProcessing, TaskStatusCancelled},
+	// pending — повтор после ошибки или потери аренды, blocked — ожидание дочерних задач
+	TaskStatusProcessing: {TaskStatusCompleted, TaskStatusFailed, TaskStatusDead, TaskStatusCancelled,
+		TaskStatusPending, TaskStatusBlocked},
+	TaskStatusFailed: {TaskStatusPending, TaskStatusBlocked},
+	TaskStatusDead:   {TaskStatusPending, TaskStatusBlocked},
+}
+
+// CanTransitionTo сообщает, может ли задача перейти из статуса s в статус to
+func (s TaskStatus) CanTransitionTo(to TaskStatus) bool {
+	for _, allowed := range taskTransitions[s] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateTransition возвращает ErrInvalidTransition, если переход из статуса from в статус to запрещен
+func ValidateTransition(from, to TaskStatus) error {
+	if !from.CanTransitionTo(to) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, from, to)
+	}
+	return nil
+}
+
+// Допустимый диапазон приоритета задачи; чем больше значение, тем раньше задача будет выполнена
+const (
+	MinTaskPriority = 0
+	MaxTaskPriority = 100
+)
+
+const (
+	// ErrorCodeTimeout означает, что задача была прервана по истечении времени выполнения
+	ErrorCodeTimeout = "timeout"
+	// ErrorCodeDependencyFailed означает, что задача не запускалась, потому что не выполнилась одна из ее зависимостей
+	ErrorCodeDependencyFailed = "dependency_failed"
+	// ErrorCodeChildFailed означает, что обработчик задачи выполнился, но не выполнилась одна из ее дочерних задач
+	ErrorCodeChildFailed = "child_failed"
+)
+
+// MaxTaskTimeoutSeconds — максимальное время выполнения задачи в секундах (сутки)
+const MaxTaskTimeoutSeconds = 24 * 60 * 60
+
+// MaxTaskDependencies — максимальное число задач, от которых может зависеть задача
+const MaxTaskDependencies = 100
+
 type Task struct {
-	ID        string          `json:"id" db:"id"`
-	Status    TaskStatus      `json:"status" db:"status"`
-	Result    json.RawMessage `json:"result,omitempty" db:"result"`
-	Error     string          `json:"error,omitempty" db:"error"`
-	CreatedAt time.Time       `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time       `json:"updated_at" db:"updated_at"`
+	ID              string          `json:"id" db:"id"`
+	Type            string          `json:"type" db:"type"`
+	Queue           string          `json:"queue" db:"queue"`
+	Status          TaskStatus      `json:"status" db:"status"`
+	Payload         json.RawMessage `json:"payload,omitempty" db:"payload"`
+	Result          json.RawMessage `json:"result,omitempty" db:"result"`
+	Error           string          `json:"error,omitempty" db:"error"`
+	ErrorCode       string          `json:"error_code,omitempty" db:"error_code"`
+	Progress        int             `json:"progress" db:"progress"`
+	ProgressMessage string          `json:"progress_message,omitempty" db:"progress_message"`
+	TimeoutSeconds  int             `json:"timeout_seconds,omitempty" db:"timeout_seconds"`
+	Priority        int             `json:"priority" db:"priority"`
+	Attempts        int             `json:"attempts" db:"attempts"`
+	MaxAttempts     int             `json:"max_attempts" db:"max_attempts"`
+	NextRunAt       time.Time       `json:"next_run_at" db:"next_run_at"`
+	AttemptHistory  TaskAttempts    `json:"attempt_history" db:"attempt_history"`
+	ScheduleID      *string         `json:"schedule_id,omitempty" db:"schedule_id"`
+	CallbackURL     string          `json:"callback_url,omitempty" db:"callback_url"`
+	UniqueKey       string          `json:"unique_key,omitempty" db:"unique_key"`
+	BatchID         *string         `json:"batch_id,omitempty" db:"batch_id"`
+	WorkflowID      *string         `json:"workflow_id,omitempty" db:"workflow_id"`
+	WorkflowStep    string          `json:"workflow_step,omitempty" db:"workflow_step"`
+	ParentID        *string         `json:"parent_id,omitempty" db:"parent_id"`
+	// DependsOn заполняется только при создании задачи; граф зависимостей возвращает TaskGraph
+	DependsOn       []string   `json:"-" db:"-"`
+	WorkerID        string     `json:"worker_id,omitempty" db:"worker_id"`
+	LockedUntil     *time.Time `json:"locked_until,omitempty" db:"locked_until"`
+	StartedAt       *time.Time `json:"started_at,omitempty" db:"started_at"`
+	CancelRequested bool       `json:"cancel_requested,omitempty" db:"cancel_requested"`
+	// Version увеличивается при каждом изменении задачи и используется для оптимистичной блокировки
+	Version   int       `json:"version" db:"version"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// TaskSpec описывает параметры создания задачи.
+// RunAt и DelaySeconds откладывают запуск задачи; одновременно можно указать только одно из них.
+// UniqueKey запрещает создавать задачу того же типа, пока не завершена задача с тем же ключом;
+// Unique без UniqueKey использует в качестве ключа хэш типа и payload.
+// DependsOn перечисляет задачи, после успешного завершения которых задача будет запущена.
+type TaskSpec struct {
+	Type           string          `json:"type"`
+	Queue          string          `json:"queue,omitempty"`
+	Payload        json.RawMessage `json:"payload,omitempty"`
+	TimeoutSeconds int             `json:"timeout_seconds,omitempty"`
+	Priority       int             `json:"priority,omitempty"`
+	RunAt          *time.Time      `json:"run_at,omitempty"`
+	DelaySeconds   int             `json:"delay_seconds,omitempty"`
+	CallbackURL    string          `json:"callback_url,omitempty"`
+	UniqueKey      string          `json:"unique_key,omitempty"`
+	Unique         bool            `json:"unique,omitempty"`
+	DependsOn      []string        `json:"depends_on,omitempty"`
+	// IdempotencyKey передается в заголовке Idempotency-Key и не входит в отпечаток запроса
+	IdempotencyKey string `json:"-"`
+	// ScheduleID заполняет планировщик для задач, созданных по расписанию
+	ScheduleID *string `json:"-"`
+}
+
+// Максимальная длина ключа идемпотентности и ключа уникальности задачи
+const (
+	MaxIdempotencyKeyLength = 255
+	MaxUniqueKeyLength      = 255
+)
+
+// IdempotencyKey связывает ключ идемпотентности запроса создания задачи с созданной по нему задачей.
+// RequestHash — отпечаток параметров запроса, по которому повтор отличается от другого запроса с тем же ключом.
+type IdempotencyKey struct {
+	Key         string    `db:"key"`
+	RequestHash string    `db:"request_hash"`
+	TaskID      string    `db:"task_id"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+// MaxTaskBatchSize — максимальное число задач в одной пачке
+const MaxTaskBatchSize = 1000
+
+// TaskBatch описывает пачку задач, созданных одним запросом
+type TaskBatch struct {
+	ID      string   `json:"batch_id"`
+	TaskIDs []string `json:"task_ids"`
+}
+
+// TaskDependency — ребро графа зависимостей: задача TaskID ждет успешного завершения задачи DependsOn
+type TaskDependency struct {
+	TaskID    string `json:"task_id" db:"task_id"`
+	DependsOn string `json:"depends_on" db:"depends_on"`
+}
+
+// TaskGraphNode — задача в графе зависимостей
+type TaskGraphNode struct {
+	ID       string     `json:"id" db:"id"`
+	Type     string     `json:"type" db:"type"`
+	Status   TaskStatus `json:"status" db:"status"`
+	Progress int        `json:"progress" db:"progress"`
+}
+
+// TaskGraph описывает связный граф зависимостей, в который входит задача
+type TaskGraph struct {
+	Nodes []*TaskGraphNode  `json:"nodes"`
+	Edges []*TaskDependency `json:"edges"`
+}
+
+// TaskChildren описывает дочерние задачи: Counts — число дочерних задач в каждом статусе, Tasks — страница списка
+type TaskChildren struct {
+	Total  int                `json:"total"`
+	Counts map[TaskStatus]int `json:"counts"`
+	Tasks  []*Task            `json:"tasks"`
+}
+
+// TaskFilter описывает параметры выборки списка задач
+type TaskFilter struct {
+	Status   TaskStatus
+	BatchID  string
+	ParentID string
+	Limit    int
+	Offset   int
+}
+
+// ClaimOptions описывает параметры захвата задачи воркером очереди Queue.
+// Каждые PriorityAging ожидания в очереди повышают эффективный приоритет задачи на единицу.
+// MaxInFlight ограничивает число одновременно выполняемых задач очереди на всех репликах (0 — без лимита).
+type ClaimOptions struct {
+	WorkerID      string
+	Queue         string
+	LeaseDuration time.Duration
+	TaskTypes     []string
+	PriorityAging time.Duration
+	MaxInFlight   int
+}
+
+// QueueStats описывает состояние именованной очереди
+type QueueStats struct {
+	Name                    string  `json:"name" db:"queue"`
+	Workers                 int     `json:"workers" db:"-"`
+	MaxInFlight             int     `json:"max_in_flight" db:"-"`
+	Depth                   int     `json:"depth" db:"depth"`
+	Running                 int     `json:"running" db:"running"`
+	OldestPendingAgeSeconds float64 `json:"oldest_pending_age_seconds" db:"oldest_pending_age_seconds"`
+}
+
+// TaskAttempt описывает одну попытку выполнения задачи
+type TaskAttempt struct {
+	Attempt    int       `json:"attempt"`
+	WorkerID   string    `json:"worker_id,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// TaskAttempts хранится в JSONB-колонке attempt_history
+type TaskAttempts []TaskAttempt
+
+// Scan реализует sql.Scanner для чтения истории попыток из JSONB
+func (a *TaskAttempts) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = TaskAttempts{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, a)
+	case string:
+		return json.Unmarshal([]byte(v), a)
+	default:
+		return fmt.Errorf("unsupported attempt history type %T", src)
+	}
+}
+
+// Value реализует driver.Valuer для записи истории попыток в JSONB
+func (a TaskAttempts) Value() (driver.Value, error) {
+	if a == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(a)
 }
@@ -0,0 +1,65 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestValidateTransition тестирует отдельные переходы жизненного цикла задачи
+func TestValidateTransition(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    TaskStatus
+		to      TaskStatus
+		allowed bool
+	}{
+		{"completed task is not executed again", TaskStatusCompleted, TaskStatusProcessing, false},
+		{"cancelled task is not requeued", TaskStatusCancelled, TaskStatusPending, false},
+		{"blocked task is released", TaskStatusBlocked, TaskStatusPending, true},
+		{"pending task is claimed", TaskStatusPending, TaskStatusProcessing, true},
+		{"pending task is not completed without execution", TaskStatusPending, TaskStatusCompleted, false},
+		{"scheduled task becomes due", TaskStatusScheduled, TaskStatusPending, true},
+		{"processing task waits for children", TaskStatusProcessing, TaskStatusBlocked, true},
+		{"failed task is retried", TaskStatusFailed, TaskStatusPending, true},
+		{"dead task is retried", TaskStatusDead, TaskStatusPending, true},
+		{"failed task is not executed without retry", TaskStatusFailed, TaskStatusProcessing, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTransition(tt.from, tt.to)
+
+			assert.Equal(t, tt.allowed, tt.from.CanTransitionTo(tt.to))
+			if tt.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidTransition)
+			}
+		})
+	}
+}
+
+// TestValidateTransition_Terminal тестирует, что из конечных статусов нет переходов,
+// кроме повтора задач в статусах failed и dead
+func TestValidateTransition_Terminal(t *testing.T) {
+	statuses := []TaskStatus{
+		TaskStatusBlocked, TaskStatusScheduled, TaskStatusPending, TaskStatusProcessing,
+		TaskStatusCompleted, TaskStatusFailed, TaskStatusCancelled, TaskStatusDead,
+	}
+	retries := map[TaskStatus]map[TaskStatus]bool{
+		TaskStatusFailed: {TaskStatusPending: true, TaskStatusBlocked: true},
+		TaskStatusDead:   {TaskStatusPending: true, TaskStatusBlocked: true},
+	}
+
+	for _, from := range statuses {
+		if !from.IsTerminal() {
+			continue
+		}
+		for _, to := range statuses {
+			t.Run(string(from)+"->"+string(to), func(t *testing.T) {
+				assert.Equal(t, retries[from][to], from.CanTransitionTo(to))
+			})
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package entity
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type WebhookStatus string
+
+const (
+	WebhookStatusPending   WebhookStatus = "pending"
+	WebhookStatusDelivered WebhookStatus = "delivered"
+	WebhookStatusFailed    WebhookStatus = "failed"
+)
+
+// События задачи, о которых сообщают вебхуки
+const (
+	WebhookEventTaskCompleted = "task.completed"
+	WebhookEventTaskFailed    = "task.failed"
+)
+
+// WebhookDelivery описывает доставку уведомления о завершении задачи на ее callback_url.
+// Payload — JSON задачи на момент завершения; LastStatusCode равен 0, если ответ не был получен.
+type WebhookDelivery struct {
+	ID             string          `json:"id" db:"id"`
+	TaskID         string          `json:"task_id" db:"task_id"`
+	URL            string          `json:"url" db:"url"`
+	Event          string          `json:"event" db:"event"`
+	Payload        json.RawMessage `json:"-" db:"payload"`
+	Status         WebhookStatus   `json:"status" db:"status"`
+	Attempts       int             `json:"attempts" db:"attempts"`
+	MaxAttempts    int             `json:"max_attempts" db:"max_attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at" db:"next_attempt_at"`
+	LastStatusCode int             `json:"last_status_code,omitempty" db:"last_status_code"`
+	LastError      string          `json:"last_error,omitempty" db:"last_error"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty" db:"delivered_at"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// NewWebhookDelivery возвращает доставку вебхука о завершении задачи или nil, если вебхук не нужен:
+// у задачи нет callback_url или она не завершилась успешно и не упала окончательно
+func NewWebhookDelivery(task *Task, maxAttempts int) (*WebhookDelivery, error) {
+	if task.CallbackURL == "" {
+		return nil, nil
+	}
+
+	var event string
+	switch task.Status {
+	case TaskStatusCompleted:
+		event = WebhookEventTaskCompleted
+	case TaskStatusFailed, TaskStatusDead:
+		event = WebhookEventTaskFailed
+	default:
+		return nil, nil
+	}
+
+	payload, err := json.Marshal(task)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	return &WebhookDelivery{
+		TaskID:      task.ID,
+		URL:         task.CallbackURL,
+		Event:       event,
+		Payload:     payload,
+		Status:      WebhookStatusPending,
+		MaxAttempts: maxAttempts,
+	}, nil
+}
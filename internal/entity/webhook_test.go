@@ -0,0 +1,43 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestNewWebhookDelivery тестирует создание доставки вебхука только для завершенных задач с callback_url
+func TestNewWebhookDelivery(t *testing.T) {
+	tests := []struct {
+		name      string
+		task      *Task
+		wantEvent string
+	}{
+		{"completed", &Task{ID: "task-id", Status: TaskStatusCompleted, CallbackURL: "https://example.com/hook"}, WebhookEventTaskCompleted},
+		{"failed", &Task{ID: "task-id", Status: TaskStatusFailed, CallbackURL: "https://example.com/hook"}, WebhookEventTaskFailed},
+		{"dead", &Task{ID: "task-id", Status: TaskStatusDead, CallbackURL: "https://example.com/hook"}, WebhookEventTaskFailed},
+		{"pending", &Task{ID: "task-id", Status: TaskStatusPending, CallbackURL: "https://example.com/hook"}, ""},
+		{"cancelled", &Task{ID: "task-id", Status: TaskStatusCancelled, CallbackURL: "https://example.com/hook"}, ""},
+		{"no callback", &Task{ID: "task-id", Status: TaskStatusCompleted}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delivery, err := NewWebhookDelivery(tt.task, 3)
+
+			assert.NoError(t, err)
+			if tt.wantEvent == "" {
+				assert.Nil(t, delivery)
+				return
+			}
+			if assert.NotNil(t, delivery) {
+				assert.Equal(t, tt.wantEvent, delivery.Event)
+				assert.Equal(t, "task-id", delivery.TaskID)
+				assert.Equal(t, "https://example.com/hook", delivery.URL)
+				assert.Equal(t, WebhookStatusPending, delivery.Status)
+				assert.Equal(t, 3, delivery.MaxAttempts)
+				assert.Contains(t, string(delivery.Payload), `"status":"`+string(tt.task.Status)+`"`)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type WorkflowStatus string
+
+const (
+	WorkflowStatusPending   WorkflowStatus = "pending"
+	WorkflowStatusRunning   WorkflowStatus = "running"
+	WorkflowStatusCompleted WorkflowStatus = "completed"
+	WorkflowStatusFailed    WorkflowStatus = "failed"
+	WorkflowStatusCancelled WorkflowStatus = "cancelled"
+)
+
+// MaxWorkflowSteps — максимальное число шагов в workflow
+const MaxWorkflowSteps = 100
+
+// Workflow описывает цепочку задач, созданных по шагам Steps. Status вычисляется по статусам задач Tasks,
+// которые перечислены в порядке шагов.
+type Workflow struct {
+	ID        string          `json:"id" db:"id"`
+	Name      string          `json:"name" db:"name"`
+	Status    WorkflowStatus  `json:"status" db:"-"`
+	Input     json.RawMessage `json:"input,omitempty" db:"input"`
+	Steps     WorkflowSteps   `json:"steps" db:"steps"`
+	Tasks     []*Task         `json:"tasks" db:"-"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+}
+
+// WorkflowSpec описывает параметры создания workflow. Input передается в payload шагов без предшественников.
+type WorkflowSpec struct {
+	Name  string          `json:"name"`
+	Input json.RawMessage `json:"input,omitempty"`
+	Steps WorkflowSteps   `json:"steps"`
+}
+
+// WorkflowStep описывает шаг workflow — задачу типа Type, которая запускается после успешного завершения
+// шагов After. Без After шаг идет после предыдущего, а с пустым списком не ждет других шагов.
+// Шаг получает в payload result единственного предшественника, а при нескольких — объект
+// с их результатами по именам шагов.
+type WorkflowStep struct {
+	Name           string   `json:"name"`
+	Type           string   `json:"type"`
+	Queue          string   `json:"queue,omitempty"`
+	TimeoutSeconds int      `json:"timeout_seconds,omitempty"`
+	Priority       int      `json:"priority,omitempty"`
+	After          []string `json:"after"`
+}
+
+// WorkflowSteps хранится в JSONB-колонке steps
+type WorkflowSteps []WorkflowStep
+
+// Scan реализует sql.Scanner для чтения шагов workflow из JSONB
+func (s *WorkflowSteps) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*s = WorkflowSteps{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	default:
+		return fmt.Errorf("unsupported workflow steps type %T", src)
+	}
+}
+
+// Value реализует driver.Valuer для записи шагов workflow в JSONB
+func (s WorkflowSteps) Value() (driver.Value, error) {
+	if s == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(s)
+}
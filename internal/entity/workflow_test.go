@@ -0,0 +1,25 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWorkflowSteps_RoundTrip тестирует, что пустой after у шага без предшественников сохраняется в JSONB
+// и не превращается после чтения в отсутствующий after, означающий шаг после предыдущего
+func TestWorkflowSteps_RoundTrip(t *testing.T) {
+	steps := WorkflowSteps{
+		{Name: "fetch", Type: "test", After: []string{}},
+		{Name: "render", Type: "test", After: []string{}},
+	}
+
+	value, err := steps.Value()
+	assert.NoError(t, err)
+	assert.Contains(t, string(value.([]byte)), `"after":[]`)
+
+	var scanned WorkflowSteps
+	assert.NoError(t, scanned.Scan(value))
+	assert.Equal(t, steps, scanned)
+	assert.NotNil(t, scanned[1].After)
+}
@@ -0,0 +1,200 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Egorpalan/workmate-test/internal/entity"
+	"github.com/Egorpalan/workmate-test/pkg/logger"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// scheduleColumns перечисляет колонки, из которых собирается entity.Schedule
+const scheduleColumns = "id, name, cron_expression, task_type, payload, timezone, enabled, " +
+	"next_run_at, last_run_at, created_at, updated_at"
+
+type ScheduleRepository struct {
+	db *sqlx.DB
+}
+
+// NewScheduleRepository создает новый экземпляр ScheduleRepository
+func NewScheduleRepository(db *sqlx.DB) *ScheduleRepository {
+	return &ScheduleRepository{
+		db: db,
+	}
+}
+
+// Create создает новое расписание в базе данных
+func (r *ScheduleRepository) Create(ctx context.Context, schedule *entity.Schedule) error {
+	query := `
+        INSERT INTO schedules (name, cron_expression, task_type, payload, timezone, enabled, next_run_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING id, created_at, updated_at
+    `
+
+	row := r.db.QueryRowxContext(
+		ctx,
+		query,
+		schedule.Name,
+		schedule.CronExpression,
+		schedule.TaskType,
+		schedule.Payload,
+		schedule.Timezone,
+		schedule.Enabled,
+		schedule.NextRunAt,
+	)
+
+	err := row.Scan(&schedule.ID, &schedule.CreatedAt, &schedule.UpdatedAt)
+	if err != nil {
+		logger.Error("Failed to create schedule", zap.Error(err))
+		return fmt.Errorf("failed to create schedule: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID возвращает расписание по его ID
+func (r *ScheduleRepository) GetByID(ctx context.Context, id string) (*entity.Schedule, error) {
+	query := `
+        SELECT ` + scheduleColumns + `
+        FROM schedules
+        WHERE id = $1
+    `
+
+	var schedule entity.Schedule
+	err := r.db.GetContext(ctx, &schedule, query, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, entity.ErrScheduleNotFound
+	}
+	if err != nil {
+		logger.Error("Failed to get schedule by ID", zap.String("id", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to get schedule by id: %w", err)
+	}
+
+	return &schedule, nil
+}
+
+// Update обновляет существующее расписание
+func (r *ScheduleRepository) Update(ctx context.Context, schedule *entity.Schedule) error {
+	query := `
+        UPDATE schedules
+        SET name = $1, cron_expression = $2, task_type = $3, payload = $4,
+            timezone = $5, enabled = $6, next_run_at = $7, updated_at = NOW()
+        WHERE id = $8
+        RETURNING updated_at
+    `
+
+	row := r.db.QueryRowContext(
+		ctx,
+		query,
+		schedule.Name,
+		schedule.CronExpression,
+		schedule.TaskType,
+		schedule.Payload,
+		schedule.Timezone,
+		schedule.Enabled,
+		schedule.NextRunAt,
+		schedule.ID,
+	)
+
+	err := row.Scan(&schedule.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entity.ErrScheduleNotFound
+	}
+	if err != nil {
+		logger.Error("Failed to update schedule", zap.String("id", schedule.ID), zap.Error(err))
+		return fmt.Errorf("failed to update schedule: %w", err)
+	}
+
+	return nil
+}
+
+// Delete удаляет расписание; уже созданные по нему задачи сохраняются
+func (r *ScheduleRepository) Delete(ctx context.Context, id string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM schedules WHERE id = $1`, id)
+	if err != nil {
+		logger.Error("Failed to delete schedule", zap.String("id", id), zap.Error(err))
+		return fmt.Errorf("failed to delete schedule: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete schedule: %w", err)
+	}
+	if affected == 0 {
+		return entity.ErrScheduleNotFound
+	}
+
+	return nil
+}
+
+// List возвращает список расписаний с пагинацией
+func (r *ScheduleRepository) List(ctx context.Context, limit, offset int) ([]*entity.Schedule, error) {
+	query := `
+        SELECT ` + scheduleColumns + `
+        FROM schedules
+        ORDER BY created_at DESC
+        LIMIT $1 OFFSET $2
+    `
+
+	schedules := make([]*entity.Schedule, 0)
+	err := r.db.SelectContext(ctx, &schedules, query, limit, offset)
+	if err != nil {
+		logger.Error("Failed to list schedules", zap.Error(err))
+		return nil, fmt.Errorf("failed to list schedules: %w", err)
+	}
+
+	return schedules, nil
+}
+
+// ListDue возвращает включенные расписания, время запуска которых наступило к моменту now
+func (r *ScheduleRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]*entity.Schedule, error) {
+	query := `
+        SELECT ` + scheduleColumns + `
+        FROM schedules
+        WHERE enabled AND next_run_at <= $1
+        ORDER BY next_run_at
+        LIMIT $2
+    `
+
+	schedules := make([]*entity.Schedule, 0)
+	err := r.db.SelectContext(ctx, &schedules, query, now, limit)
+	if err != nil {
+		logger.Error("Failed to list due schedules", zap.Error(err))
+		return nil, fmt.Errorf("failed to list due schedules: %w", err)
+	}
+
+	return schedules, nil
+}
+
+// Fire отмечает срабатывание расписания, сдвигая его next_run_at на nextRunAt.
+// Сдвиг выполняется только если next_run_at не изменился с момента чтения, поэтому срабатывание
+// отмечает ровно одна реплика. Задачу срабатывания реплики создают до сдвига с общим ключом идемпотентности
+// entity.ScheduleIdempotencyKey, поэтому она создается один раз. Возвращает false, если расписание
+// уже обработано другой репликой.
+func (r *ScheduleRepository) Fire(ctx context.Context, schedule *entity.Schedule, nextRunAt time.Time) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+        UPDATE schedules
+        SET next_run_at = $1, last_run_at = NOW(), updated_at = NOW()
+        WHERE id = $2 AND enabled AND next_run_at = $3
+    `, nextRunAt, schedule.ID, schedule.NextRunAt)
+	if err != nil {
+		logger.Error("Failed to advance schedule", zap.String("id", schedule.ID), zap.Error(err))
+		return false, fmt.Errorf("failed to advance schedule: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to advance schedule: %w", err)
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	schedule.NextRunAt = nextRunAt
+	return true, nil
+}
@@ -0,0 +1,104 @@
+package postgresql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Egorpalan/workmate-test/internal/entity"
+	"github.com/Egorpalan/workmate-test/pkg/logger"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// taskEventsChannel — канал LISTEN/NOTIFY, через который реплики обмениваются событиями задач
+const taskEventsChannel = "task_events"
+
+// maxNotifyPayload — предельный размер уведомления NOTIFY в Postgres (8000 байт) с запасом
+const maxNotifyPayload = 7900
+
+// listenerPingInterval — как часто проверяется соединение LISTEN, если уведомлений нет
+const listenerPingInterval = 90 * time.Second
+
+type TaskEventBus struct {
+	db  *sqlx.DB
+	dsn string
+}
+
+// NewTaskEventBus создает новый экземпляр TaskEventBus.
+// Уведомления отправляются через db, а для LISTEN открывается отдельное соединение по dsn.
+func NewTaskEventBus(db *sqlx.DB, dsn string) *TaskEventBus {
+	return &TaskEventBus{
+		db:  db,
+		dsn: dsn,
+	}
+}
+
+// Publish отправляет событие всем репликам, включая текущую, через pg_notify.
+// Слишком длинные тексты ошибки и прогресса отбрасываются, чтобы уложиться в лимит NOTIFY.
+func (b *TaskEventBus) Publish(ctx context.Context, event entity.TaskEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task event: %w", err)
+	}
+	if len(payload) > maxNotifyPayload {
+		event.Error = ""
+		event.ProgressMessage = ""
+		if payload, err = json.Marshal(event); err != nil {
+			return fmt.Errorf("failed to marshal task event: %w", err)
+		}
+	}
+
+	if _, err := b.db.ExecContext(ctx, `SELECT pg_notify($1, $2)`, taskEventsChannel, string(payload)); err != nil {
+		logger.Error("Failed to publish task event", zap.String("task_id", event.TaskID), zap.Error(err))
+		return fmt.Errorf("failed to publish task event: %w", err)
+	}
+
+	return nil
+}
+
+// Listen подписывается на события задач и вызывает handler для каждого из них, пока не отменен ctx.
+// При потере соединения слушатель переподключается сам; события, отправленные в это время, теряются.
+func (b *TaskEventBus) Listen(ctx context.Context, handler func(entity.TaskEvent)) error {
+	listener := pq.NewListener(b.dsn, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			logger.Warn("Task event listener connection problem", zap.Error(err))
+		}
+	})
+	defer func() {
+		_ = listener.Close()
+	}()
+
+	if err := listener.Listen(taskEventsChannel); err != nil {
+		logger.Error("Failed to listen for task events", zap.Error(err))
+		return fmt.Errorf("failed to listen for task events: %w", err)
+	}
+
+	ticker := time.NewTicker(listenerPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			go func() {
+				_ = listener.Ping()
+			}()
+		case notification := <-listener.Notify:
+			if notification == nil {
+				// nil приходит после переподключения
+				continue
+			}
+
+			var event entity.TaskEvent
+			if err := json.Unmarshal([]byte(notification.Extra), &event); err != nil {
+				logger.Warn("Malformed task event", zap.String("payload", notification.Extra), zap.Error(err))
+				continue
+			}
+			handler(event)
+		}
+	}
+}
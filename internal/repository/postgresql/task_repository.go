@@ -2,45 +2,537 @@ package postgresql
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/Egorpalan/workmate-test/internal/entity"
 	"github.com/Egorpalan/workmate-test/pkg/logger"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"go.uber.org/zap"
 )
 
+// claimCandidates — сколько задач с наибольшим приоритетом и сколько дольше всего ждущих задач
+// ClaimNext сравнивает по эффективному приоритету
+const claimCandidates = 100
+
+// maxUniqueInsertAttempts ограничивает число попыток вставки задачи, чей unique_key освобождается конкурентно
+const maxUniqueInsertAttempts = 3
+
+// Коды ошибок Postgres: нарушение уникального индекса и некорректное текстовое значение (например, UUID)
+const (
+	uniqueViolation           = "23505"
+	invalidTextRepresentation = "22P02"
+)
+
+// taskColumns перечисляет колонки, из которых собирается entity.Task
+const taskColumns = "id, type, queue, status, payload, result, error, error_code, progress, progress_message, timeout_seconds, " +
+	"priority, callback_url, unique_key, batch_id, workflow_id, workflow_step, parent_id, attempts, max_attempts, next_run_at, attempt_history, schedule_id, " +
+	"worker_id, locked_until, started_at, cancel_requested, version, created_at, updated_at"
+
+// pgInterval форматирует длительность как значение для параметра типа interval
+func pgInterval(d time.Duration) string {
+	return fmt.Sprintf("%d milliseconds", d.Milliseconds())
+}
+
+// nullTime превращает нулевое время в NULL, чтобы сработало значение по умолчанию в запросе
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
 type TaskRepository struct {
 	db *sqlx.DB
+	// webhookMaxAttempts — лимит попыток доставки вебхуков, которые сохраняются вместе с завершением задач
+	webhookMaxAttempts int
 }
 
 // NewTaskRepository создает новый экземпляр TaskRepository
-func NewTaskRepository(db *sqlx.DB) *TaskRepository {
+func NewTaskRepository(db *sqlx.DB, webhookMaxAttempts int) *TaskRepository {
 	return &TaskRepository{
-		db: db,
+		db:                 db,
+		webhookMaxAttempts: webhookMaxAttempts,
 	}
 }
 
-// Create создает новую задачу в базе данных
-func (r *TaskRepository) Create(ctx context.Context, task *entity.Task) error {
-	query := `
-        INSERT INTO tasks (status, result, error)
+// updateWithWebhooks выполняет запрос query, меняющий статус задач, и в той же транзакции сохраняет
+// доставки вебхуков о задачах, которые он завершил. Возвращает задачи из RETURNING запроса.
+func (r *TaskRepository) updateWithWebhooks(ctx context.Context, query string, args ...interface{}) ([]*entity.Task, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	tasks := make([]*entity.Task, 0)
+	if err := tx.SelectContext(ctx, &tasks, query, args...); err != nil {
+		return nil, err
+	}
+	if err := insertWebhookDeliveries(ctx, tx, tasks, r.webhookMaxAttempts); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return tasks, nil
+}
+
+// Create создает новую задачу в базе данных.
+// Если незавершенная задача того же типа с тем же unique_key уже есть, task заполняется ею и возвращается false.
+// Задача с зависимостями создается в транзакции вместе с ребрами графа.
+func (r *TaskRepository) Create(ctx context.Context, task *entity.Task) (bool, error) {
+	if len(task.DependsOn) == 0 {
+		return insertTask(ctx, r.db, task)
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		logger.Error("Failed to begin create transaction", zap.Error(err))
+		return false, fmt.Errorf("failed to create task: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	created, err := insertTask(ctx, tx, task)
+	if err != nil {
+		return false, err
+	}
+	// Задача, зависимость которой уже не выполнилась, создается сразу упавшей
+	if created {
+		if err := insertWebhookDeliveries(ctx, tx, []*entity.Task{task}, r.webhookMaxAttempts); err != nil {
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Failed to commit create transaction", zap.Error(err))
+		return false, fmt.Errorf("failed to create task: %w", err)
+	}
+
+	return created, nil
+}
+
+// CreateIdempotent создает задачу и сохраняет за ней ключ идемпотентности key в одной транзакции.
+// Если ключ уже использован не раньше window назад, задача не создается, а возвращаются сохраненный ключ и false.
+// Если задача не создана из-за unique_key, ключ сохраняется за существующей задачей и тоже возвращается false.
+// Ключ блокируется advisory-локом Postgres, поэтому одновременные запросы с одним ключом создают одну задачу.
+func (r *TaskRepository) CreateIdempotent(
+	ctx context.Context,
+	task *entity.Task,
+	key, requestHash string,
+	window time.Duration,
+) (*entity.IdempotencyKey, bool, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		logger.Error("Failed to begin idempotent create transaction", zap.Error(err))
+		return nil, false, fmt.Errorf("failed to create task: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('idempotency:' || $1))`, key)
+	if err != nil {
+		logger.Error("Failed to lock idempotency key", zap.String("key", key), zap.Error(err))
+		return nil, false, fmt.Errorf("failed to lock idempotency key: %w", err)
+	}
+
+	// Устаревший ключ освобождается, чтобы его можно было использовать снова
+	_, err = tx.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE key = $1 AND created_at < NOW() - $2::interval`,
+		key, pgInterval(window))
+	if err != nil {
+		logger.Error("Failed to delete expired idempotency key", zap.String("key", key), zap.Error(err))
+		return nil, false, fmt.Errorf("failed to delete expired idempotency key: %w", err)
+	}
+
+	var stored entity.IdempotencyKey
+	err = tx.GetContext(ctx, &stored,
+		`SELECT key, request_hash, task_id, created_at FROM idempotency_keys WHERE key = $1`, key)
+	if err == nil {
+		return &stored, false, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		logger.Error("Failed to get idempotency key", zap.String("key", key), zap.Error(err))
+		return nil, false, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+
+	created, err := insertTask(ctx, tx, task)
+	if err != nil {
+		return nil, false, err
+	}
+	if created {
+		if err := insertWebhookDeliveries(ctx, tx, []*entity.Task{task}, r.webhookMaxAttempts); err != nil {
+			return nil, false, err
+		}
+	}
+
+	err = tx.GetContext(ctx, &stored, `
+        INSERT INTO idempotency_keys (key, request_hash, task_id)
         VALUES ($1, $2, $3)
-        RETURNING id, created_at, updated_at
+        RETURNING key, request_hash, task_id, created_at
+    `, key, requestHash, task.ID)
+	if err != nil {
+		logger.Error("Failed to save idempotency key", zap.String("key", key), zap.Error(err))
+		return nil, false, fmt.Errorf("failed to save idempotency key: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Failed to commit idempotent create transaction", zap.Error(err))
+		return nil, false, fmt.Errorf("failed to create task: %w", err)
+	}
+
+	return &stored, created, nil
+}
+
+// CreateMany создает задачи одной пачкой в одной транзакции многострочным INSERT, присваивает им общий batch_id
+// и возвращает его. Задачи, совпавшие по unique_key с незавершенными, не создаются и заполняются существующими задачами.
+func (r *TaskRepository) CreateMany(ctx context.Context, tasks []*entity.Task) (string, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		logger.Error("Failed to begin batch transaction", zap.Error(err))
+		return "", fmt.Errorf("failed to create tasks: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// ID пачки и задач генерируются заранее, чтобы сопоставить строки RETURNING с задачами
+	var ids []string
+	err = tx.SelectContext(ctx, &ids, `SELECT uuid_generate_v4()::text FROM generate_series(0, $1)`, len(tasks))
+	if err != nil {
+		logger.Error("Failed to generate task IDs", zap.Error(err))
+		return "", fmt.Errorf("failed to generate task ids: %w", err)
+	}
+	batchID := ids[0]
+
+	const columnsPerTask = 16
+	values := make([]string, 0, len(tasks))
+	args := make([]interface{}, 0, len(tasks)*columnsPerTask)
+	byID := make(map[string]*entity.Task, len(tasks))
+	for i, task := range tasks {
+		if err := resolveDependencies(ctx, tx, task); err != nil {
+			return "", err
+		}
+
+		id := ids[i+1]
+		byID[id] = task
+		task.BatchID = &batchID
+
+		n := i * columnsPerTask
+		values = append(values, fmt.Sprintf(
+			"($%d::uuid, $%d, $%d, $%d::jsonb, $%d::jsonb, $%d, $%d::integer, $%d::integer, "+
+				"COALESCE($%d::timestamptz, NOW()), $%d::integer, $%d, $%d, $%d, $%d::uuid, $%d, $%d::uuid)",
+			n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9, n+10, n+11, n+12, n+13, n+14, n+15, n+16))
+		args = append(args,
+			id,
+			task.Type,
+			task.Status,
+			task.Payload,
+			task.Result,
+			task.Error,
+			task.MaxAttempts,
+			task.TimeoutSeconds,
+			nullTime(task.NextRunAt),
+			task.Priority,
+			task.Queue,
+			task.CallbackURL,
+			task.UniqueKey,
+			batchID,
+			task.ErrorCode,
+			task.ParentID,
+		)
+	}
+
+	query := `
+        INSERT INTO tasks (id, type, status, payload, result, error, max_attempts, timeout_seconds,
+                           next_run_at, priority, queue, callback_url, unique_key, batch_id, error_code, parent_id)
+        VALUES ` + strings.Join(values, ", ") + `
+        ON CONFLICT (type, unique_key)
+            WHERE unique_key <> '' AND status IN ('blocked', 'scheduled', 'pending', 'processing')
+            DO NOTHING
+        RETURNING id, next_run_at, version, created_at, updated_at
     `
 
-	row := r.db.QueryRowxContext(
+	rows, err := tx.QueryxContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("Failed to create tasks", zap.Int("count", len(tasks)), zap.Error(err))
+		return "", fmt.Errorf("failed to create tasks: %w", err)
+	}
+
+	inserted := make(map[*entity.Task]bool, len(tasks))
+	for rows.Next() {
+		var created entity.Task
+		if err := rows.Scan(&created.ID, &created.NextRunAt, &created.Version, &created.CreatedAt, &created.UpdatedAt); err != nil {
+			_ = rows.Close()
+			logger.Error("Failed to scan created task", zap.Error(err))
+			return "", fmt.Errorf("failed to create tasks: %w", err)
+		}
+		task := byID[created.ID]
+		task.ID, task.NextRunAt, task.Version = created.ID, created.NextRunAt, created.Version
+		task.CreatedAt, task.UpdatedAt = created.CreatedAt, created.UpdatedAt
+		inserted[task] = true
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("Failed to create tasks", zap.Error(err))
+		return "", fmt.Errorf("failed to create tasks: %w", err)
+	}
+
+	// Вставка пропущена только для задач с занятым unique_key: находим для них существующие задачи
+	created := make([]*entity.Task, 0, len(tasks))
+	for _, task := range tasks {
+		if inserted[task] {
+			if err := insertDependencies(ctx, tx, task); err != nil {
+				return "", err
+			}
+			created = append(created, task)
+			continue
+		}
+		ok, err := insertTask(ctx, tx, task)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			created = append(created, task)
+		}
+	}
+	if err := insertWebhookDeliveries(ctx, tx, created, r.webhookMaxAttempts); err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Failed to commit batch transaction", zap.Error(err))
+		return "", fmt.Errorf("failed to create tasks: %w", err)
+	}
+
+	return batchID, nil
+}
+
+// HasIdempotencyKey сообщает, использован ли ключ идемпотентности key не раньше window назад
+func (r *TaskRepository) HasIdempotencyKey(ctx context.Context, key string, window time.Duration) (bool, error) {
+	query := `
+        SELECT EXISTS (
+            SELECT 1 FROM idempotency_keys WHERE key = $1 AND created_at >= NOW() - $2::interval
+        )
+    `
+
+	var exists bool
+	err := r.db.GetContext(ctx, &exists, query, key, pgInterval(window))
+	if err != nil {
+		logger.Error("Failed to check idempotency key", zap.String("key", key), zap.Error(err))
+		return false, fmt.Errorf("failed to check idempotency key: %w", err)
+	}
+
+	return exists, nil
+}
+
+// HasUnfinished сообщает, есть ли незавершенная задача типа taskType с ключом уникальности uniqueKey
+func (r *TaskRepository) HasUnfinished(ctx context.Context, taskType, uniqueKey string) (bool, error) {
+	query := `
+        SELECT EXISTS (
+            SELECT 1 FROM tasks WHERE type = $1 AND unique_key = $2 AND status IN ($3, $4, $5, $6)
+        )
+    `
+
+	var exists bool
+	err := r.db.GetContext(ctx, &exists, query, taskType, uniqueKey, entity.TaskStatusBlocked,
+		entity.TaskStatusScheduled, entity.TaskStatusPending, entity.TaskStatusProcessing)
+	if err != nil {
+		logger.Error("Failed to check unfinished task", zap.String("unique_key", uniqueKey), zap.Error(err))
+		return false, fmt.Errorf("failed to check unfinished task: %w", err)
+	}
+
+	return exists, nil
+}
+
+// DeleteExpiredIdempotencyKeys удаляет ключи идемпотентности старше window и возвращает их число
+func (r *TaskRepository) DeleteExpiredIdempotencyKeys(ctx context.Context, window time.Duration) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE created_at < NOW() - $1::interval`,
+		pgInterval(window))
+	if err != nil {
+		logger.Error("Failed to delete expired idempotency keys", zap.Error(err))
+		return 0, fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+
+	return deleted, nil
+}
+
+// insertTask вставляет задачу через db или транзакцию и заполняет сгенерированные поля.
+// Если незавершенная задача того же типа с тем же unique_key уже есть, вставка пропускается,
+// task заполняется существующей задачей и возвращается false.
+// Задача с зависимостями должна вставляться в транзакции, см. resolveDependencies.
+func insertTask(ctx context.Context, q sqlx.ExtContext, task *entity.Task) (bool, error) {
+	if err := resolveDependencies(ctx, q, task); err != nil {
+		return false, err
+	}
+
+	for range maxUniqueInsertAttempts {
+		created, err := tryInsertTask(ctx, q, task)
+		if err != nil {
+			return false, err
+		}
+		if created {
+			return true, insertDependencies(ctx, q, task)
+		}
+
+		query := `
+            SELECT ` + taskColumns + `
+            FROM tasks
+            WHERE type = $1 AND unique_key = $2 AND status IN ($3, $4, $5, $6)
+        `
+		err = sqlx.GetContext(ctx, q, task, query, task.Type, task.UniqueKey, entity.TaskStatusBlocked,
+			entity.TaskStatusScheduled, entity.TaskStatusPending, entity.TaskStatusProcessing)
+		if err == nil {
+			return false, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			logger.Error("Failed to get duplicate task", zap.String("unique_key", task.UniqueKey), zap.Error(err))
+			return false, fmt.Errorf("failed to get duplicate task: %w", err)
+		}
+		// Существующая задача успела завершиться между вставкой и чтением, пробуем вставить снова
+	}
+
+	return false, fmt.Errorf("failed to create task: unique key %q is contended", task.UniqueKey)
+}
+
+// tryInsertTask вставляет задачу и возвращает false, если ее unique_key занят незавершенной задачей.
+// Условие ON CONFLICT должно совпадать с условием индекса idx_tasks_unique_key_unfinished.
+func tryInsertTask(ctx context.Context, q sqlx.QueryerContext, task *entity.Task) (bool, error) {
+	query := `
+        INSERT INTO tasks (type, status, payload, result, error, max_attempts, timeout_seconds,
+                           next_run_at, schedule_id, priority, queue, callback_url, unique_key, batch_id, error_code,
+                           workflow_id, workflow_step, parent_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, COALESCE($8, NOW()), $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+        ON CONFLICT (type, unique_key)
+            WHERE unique_key <> '' AND status IN ('blocked', 'scheduled', 'pending', 'processing')
+            DO NOTHING
+        RETURNING id, next_run_at, version, created_at, updated_at
+    `
+
+	row := q.QueryRowxContext(
 		ctx,
 		query,
+		task.Type,
 		task.Status,
+		task.Payload,
 		task.Result,
 		task.Error,
+		task.MaxAttempts,
+		task.TimeoutSeconds,
+		nullTime(task.NextRunAt),
+		task.ScheduleID,
+		task.Priority,
+		task.Queue,
+		task.CallbackURL,
+		task.UniqueKey,
+		task.BatchID,
+		task.ErrorCode,
+		task.WorkflowID,
+		task.WorkflowStep,
+		task.ParentID,
 	)
 
-	err := row.Scan(&task.ID, &task.CreatedAt, &task.UpdatedAt)
+	err := row.Scan(&task.ID, &task.NextRunAt, &task.Version, &task.CreatedAt, &task.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
 	if err != nil {
 		logger.Error("Failed to create task", zap.Error(err))
-		return fmt.Errorf("failed to create task: %w", err)
+		return false, fmt.Errorf("failed to create task: %w", err)
+	}
+
+	return true, nil
+}
+
+// resolveDependencies проверяет задачи из task.DependsOn и выбирает начальный статус задачи: если какая-то
+// из них уже не выполнилась, задача сразу помечается упавшей, а если не все завершены — ждет в статусе blocked.
+// Зависимости блокируются FOR SHARE до конца транзакции, поэтому их завершение, зафиксированное после вставки,
+// увидит вставленную задачу. Несуществующая зависимость дает entity.ErrDependencyNotFound.
+func resolveDependencies(ctx context.Context, q sqlx.QueryerContext, task *entity.Task) error {
+	if len(task.DependsOn) == 0 {
+		return nil
+	}
+
+	query := `
+        SELECT id, status
+        FROM tasks
+        WHERE id = ANY($1::uuid[])
+        ORDER BY id
+        FOR SHARE
+    `
+
+	var parents []struct {
+		ID     string            `db:"id"`
+		Status entity.TaskStatus `db:"status"`
+	}
+	err := sqlx.SelectContext(ctx, q, &parents, query, pq.Array(task.DependsOn))
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == invalidTextRepresentation {
+		return entity.ErrDependencyNotFound
+	}
+	if err != nil {
+		logger.Error("Failed to lock task dependencies", zap.Error(err))
+		return fmt.Errorf("failed to lock task dependencies: %w", err)
+	}
+	if len(parents) != len(task.DependsOn) {
+		return entity.ErrDependencyNotFound
+	}
+
+	blocked := false
+	for _, parent := range parents {
+		switch parent.Status {
+		case entity.TaskStatusCompleted:
+		case entity.TaskStatusFailed, entity.TaskStatusDead, entity.TaskStatusCancelled:
+			task.Status = entity.TaskStatusFailed
+			task.ErrorCode = entity.ErrorCodeDependencyFailed
+			task.Error = dependencyFailedError(parent.ID)
+			return nil
+		default:
+			blocked = true
+		}
+	}
+	if blocked {
+		task.Status = entity.TaskStatusBlocked
+	}
+
+	return nil
+}
+
+// dependencyFailedErrorFormat — шаблон ошибки задачи, не запущенной из-за зависимости.
+// Подставляется и в Go, и функцией format() в SQL, поэтому содержит только %s.
+const dependencyFailedErrorFormat = "dependency %s did not complete"
+
+// dependencyFailedError возвращает текст ошибки задачи, не запущенной из-за зависимости parentID
+func dependencyFailedError(parentID string) string {
+	return fmt.Sprintf(dependencyFailedErrorFormat, parentID)
+}
+
+// insertDependencies сохраняет ребра графа зависимостей только что вставленной задачи
+func insertDependencies(ctx context.Context, q sqlx.ExecerContext, task *entity.Task) error {
+	if len(task.DependsOn) == 0 {
+		return nil
+	}
+
+	_, err := q.ExecContext(ctx, `
+        INSERT INTO task_dependencies (task_id, depends_on)
+        SELECT $1, unnest($2::uuid[])
+    `, task.ID, pq.Array(task.DependsOn))
+	if err != nil {
+		logger.Error("Failed to save task dependencies", zap.String("id", task.ID), zap.Error(err))
+		return fmt.Errorf("failed to save task dependencies: %w", err)
 	}
 
 	return nil
@@ -49,13 +541,16 @@ func (r *TaskRepository) Create(ctx context.Context, task *entity.Task) error {
 // GetByID возвращает задачу по ее ID
 func (r *TaskRepository) GetByID(ctx context.Context, id string) (*entity.Task, error) {
 	query := `
-        SELECT id, status, result, error, created_at, updated_at
+        SELECT ` + taskColumns + `
         FROM tasks
         WHERE id = $1
     `
 
 	var task entity.Task
 	err := r.db.GetContext(ctx, &task, query, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, entity.ErrTaskNotFound
+	}
 	if err != nil {
 		logger.Error("Failed to get task by ID", zap.String("id", id), zap.Error(err))
 		return nil, fmt.Errorf("failed to get task by id: %w", err)
@@ -64,44 +559,115 @@ func (r *TaskRepository) GetByID(ctx context.Context, id string) (*entity.Task,
 	return &task, nil
 }
 
-// Update обновляет существующую задачу
-func (r *TaskRepository) Update(ctx context.Context, task *entity.Task) error {
+// Update обновляет задачу, переводя ее из статуса from в task.Status. При выходе из статуса processing аренда снимается.
+// Обновление — compare-and-swap по версии: если задача уже не в статусе from, возвращается entity.ErrInvalidTransition,
+// а если ее версия отличается от task.Version — entity.ErrConflict. При успехе task.Version увеличивается.
+// Доставка вебхука о завершении задачи сохраняется в той же транзакции.
+func (r *TaskRepository) Update(ctx context.Context, task *entity.Task, from entity.TaskStatus) error {
+	if err := entity.ValidateTransition(from, task.Status); err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		logger.Error("Failed to begin update transaction", zap.String("id", task.ID), zap.Error(err))
+		return fmt.Errorf("failed to update task: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
 	query := `
         UPDATE tasks
-        SET status = $1, result = $2, error = $3, updated_at = NOW()
-        WHERE id = $4
-        RETURNING updated_at
+        SET status = $1, result = $2, error = $3, version = version + 1, updated_at = NOW(),
+            next_run_at = $5, attempt_history = $6, error_code = $7,
+            progress = CASE WHEN $1 = 'completed' THEN 100 ELSE progress END,
+            worker_id = CASE WHEN $1 = 'processing' THEN worker_id ELSE '' END,
+            locked_until = CASE WHEN $1 = 'processing' THEN locked_until ELSE NULL END
+        WHERE id = $4 AND status = $8 AND version = $9
+        RETURNING version, updated_at
     `
 
-	row := r.db.QueryRowContext(
+	row := tx.QueryRowContext(
 		ctx,
 		query,
 		task.Status,
 		task.Result,
 		task.Error,
 		task.ID,
+		task.NextRunAt,
+		task.AttemptHistory,
+		task.ErrorCode,
+		from,
+		task.Version,
 	)
 
-	err := row.Scan(&task.UpdatedAt)
+	version, updatedAt := task.Version, task.UpdatedAt
+	err = row.Scan(&task.Version, &task.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		current, getErr := r.GetByID(ctx, task.ID)
+		if getErr != nil {
+			return getErr
+		}
+		if current.Status != from {
+			return fmt.Errorf("%w: task is %s, expected %s", entity.ErrInvalidTransition, current.Status, from)
+		}
+		return fmt.Errorf("%w: task version is %d, expected %d", entity.ErrConflict, current.Version, task.Version)
+	}
 	if err != nil {
 		logger.Error("Failed to update task", zap.String("id", task.ID), zap.Error(err))
 		return fmt.Errorf("failed to update task: %w", err)
 	}
 
+	err = insertWebhookDeliveries(ctx, tx, []*entity.Task{task}, r.webhookMaxAttempts)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		// Изменение не сохранено, поэтому задача остается в прежней версии
+		task.Version, task.UpdatedAt = version, updatedAt
+		logger.Error("Failed to commit task update", zap.String("id", task.ID), zap.Error(err))
+		return fmt.Errorf("failed to update task: %w", err)
+	}
+
 	return nil
 }
 
-// List возвращает список задач с пагинацией
-func (r *TaskRepository) List(ctx context.Context, limit, offset int) ([]*entity.Task, error) {
-	query := `
-        SELECT id, status, result, error, created_at, updated_at
+// List возвращает список задач с пагинацией, опционально отфильтрованный по статусу
+func (r *TaskRepository) List(ctx context.Context, filter entity.TaskFilter) ([]*entity.Task, error) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if filter.BatchID != "" {
+		args = append(args, filter.BatchID)
+		conditions = append(conditions, fmt.Sprintf("batch_id = $%d", len(args)))
+	}
+	if filter.ParentID != "" {
+		args = append(args, filter.ParentID)
+		conditions = append(conditions, fmt.Sprintf("parent_id = $%d", len(args)))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	query := fmt.Sprintf(`
+        SELECT `+taskColumns+`
         FROM tasks
+        %s
         ORDER BY created_at DESC
-        LIMIT $1 OFFSET $2
-    `
+        LIMIT $%d OFFSET $%d
+    `, where, len(args)-1, len(args))
 
 	tasks := make([]*entity.Task, 0)
-	err := r.db.SelectContext(ctx, &tasks, query, limit, offset)
+	err := r.db.SelectContext(ctx, &tasks, query, args...)
 	if err != nil {
 		logger.Error("Failed to list tasks", zap.Error(err))
 		return nil, fmt.Errorf("failed to list tasks: %w", err)
@@ -109,3 +675,649 @@ func (r *TaskRepository) List(ctx context.Context, limit, offset int) ([]*entity
 
 	return tasks, nil
 }
+
+// ClaimNext атомарно забирает из очереди opts.Queue ожидающую задачу одного из типов opts.TaskTypes
+// с наибольшим эффективным приоритетом, время запуска которой уже наступило, переводит ее в статус
+// processing и выдает воркеру аренду на opts.LeaseDuration. Эффективный приоритет — это priority плюс
+// число интервалов opts.PriorityAging, прошедших с next_run_at, поэтому давно ожидающие задачи
+// с низким приоритетом не голодают. При равенстве первой забирается самая старая задача.
+// Эффективный приоритет зависит от NOW() и не обслуживается индексом, поэтому он сравнивается только среди
+// claimCandidates задач с наибольшим priority и claimCandidates дольше всего ждущих задач, которые выбираются
+// по индексам. Задача вне обоих наборов может уступить задаче с меньшим эффективным приоритетом,
+// зато захват стоит не больше сортировки 2*claimCandidates строк при любой глубине очереди.
+// Кандидаты выбираются с FOR UPDATE SKIP LOCKED, поэтому одновременные захваты получают непересекающиеся наборы:
+// воркер не останется без задачи, пока другие держат его кандидатов, и не получит одну задачу с другим воркером.
+// Если задан opts.MaxInFlight, захват выполняется под advisory-локом очереди и возвращает
+// ErrNoPendingTasks, пока в очереди выполняется opts.MaxInFlight задач.
+func (r *TaskRepository) ClaimNext(ctx context.Context, opts entity.ClaimOptions) (*entity.Task, error) {
+	if opts.MaxInFlight <= 0 {
+		return claimNext(ctx, r.db, opts)
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		logger.Error("Failed to begin claim transaction", zap.Error(err))
+		return nil, fmt.Errorf("failed to claim next task: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('queue:' || $1))`, opts.Queue)
+	if err != nil {
+		logger.Error("Failed to lock queue", zap.String("queue", opts.Queue), zap.Error(err))
+		return nil, fmt.Errorf("failed to lock queue: %w", err)
+	}
+
+	var running int
+	err = tx.GetContext(ctx, &running, `SELECT COUNT(*) FROM tasks WHERE queue = $1 AND status = $2`,
+		opts.Queue, entity.TaskStatusProcessing)
+	if err != nil {
+		logger.Error("Failed to count running tasks", zap.String("queue", opts.Queue), zap.Error(err))
+		return nil, fmt.Errorf("failed to count running tasks: %w", err)
+	}
+	if running >= opts.MaxInFlight {
+		return nil, entity.ErrNoPendingTasks
+	}
+
+	task, err := claimNext(ctx, tx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Failed to commit claim transaction", zap.Error(err))
+		return nil, fmt.Errorf("failed to claim next task: %w", err)
+	}
+
+	return task, nil
+}
+
+// claimNext выполняет захват задачи для ClaimNext через q — соединение или транзакцию
+func claimNext(ctx context.Context, q sqlx.QueryerContext, opts entity.ClaimOptions) (*entity.Task, error) {
+	query := `
+        UPDATE tasks
+        SET status = $1, updated_at = NOW(), attempts = attempts + 1, version = version + 1,
+            worker_id = $3, locked_until = NOW() + $4::interval, started_at = NOW(),
+            progress = 0, progress_message = ''
+        WHERE id = (
+            SELECT id
+            FROM tasks
+            WHERE status = $2 AND id IN (
+                SELECT id FROM (
+                    SELECT id FROM tasks
+                    WHERE status = $2 AND queue = $7 AND next_run_at <= NOW() AND type = ANY($5)
+                    ORDER BY priority DESC, created_at
+                    LIMIT $8
+                    FOR UPDATE SKIP LOCKED
+                ) AS by_priority
+                UNION
+                SELECT id FROM (
+                    SELECT id FROM tasks
+                    WHERE status = $2 AND queue = $7 AND next_run_at <= NOW() AND type = ANY($5)
+                    ORDER BY next_run_at, created_at
+                    LIMIT $8
+                    FOR UPDATE SKIP LOCKED
+                ) AS by_age
+            )
+            ORDER BY priority + FLOOR(EXTRACT(EPOCH FROM NOW() - next_run_at)::float8 / $6::float8) DESC,
+                     created_at
+            FOR UPDATE SKIP LOCKED
+            LIMIT 1
+        )
+        RETURNING ` + taskColumns
+
+	var task entity.Task
+	err := sqlx.GetContext(ctx, q, &task, query,
+		entity.TaskStatusProcessing, entity.TaskStatusPending, opts.WorkerID, pgInterval(opts.LeaseDuration),
+		pq.Array(opts.TaskTypes), opts.PriorityAging.Seconds(), opts.Queue, claimCandidates)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, entity.ErrNoPendingTasks
+	}
+	if err != nil {
+		logger.Error("Failed to claim next task", zap.Error(err))
+		return nil, fmt.Errorf("failed to claim next task: %w", err)
+	}
+
+	return &task, nil
+}
+
+// CountByStatus возвращает количество задач в указанном статусе
+func (r *TaskRepository) CountByStatus(ctx context.Context, status entity.TaskStatus) (int, error) {
+	query := `
+        SELECT COUNT(*)
+        FROM tasks
+        WHERE status = $1
+    `
+
+	var count int
+	err := r.db.GetContext(ctx, &count, query, status)
+	if err != nil {
+		logger.Error("Failed to count tasks", zap.String("status", string(status)), zap.Error(err))
+		return 0, fmt.Errorf("failed to count tasks: %w", err)
+	}
+
+	return count, nil
+}
+
+// QueueStats возвращает по каждой очереди, в которой есть ожидающие или выполняемые задачи,
+// число готовых к запуску задач, число выполняемых задач и возраст самой старой готовой задачи в секундах
+func (r *TaskRepository) QueueStats(ctx context.Context) ([]*entity.QueueStats, error) {
+	query := `
+        SELECT queue,
+               COUNT(*) FILTER (WHERE status = $1 AND next_run_at <= NOW()) AS depth,
+               COUNT(*) FILTER (WHERE status = $2) AS running,
+               COALESCE(EXTRACT(EPOCH FROM NOW() - MIN(next_run_at) FILTER (
+                   WHERE status = $1 AND next_run_at <= NOW()
+               ))::float8, 0) AS oldest_pending_age_seconds
+        FROM tasks
+        WHERE status IN ($1, $2)
+        GROUP BY queue
+    `
+
+	stats := make([]*entity.QueueStats, 0)
+	err := r.db.SelectContext(ctx, &stats, query, entity.TaskStatusPending, entity.TaskStatusProcessing)
+	if err != nil {
+		logger.Error("Failed to get queue stats", zap.Error(err))
+		return nil, fmt.Errorf("failed to get queue stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// UpdateProgress сохраняет прогресс выполняемой задачи, если она все еще принадлежит воркеру workerID
+func (r *TaskRepository) UpdateProgress(ctx context.Context, id, workerID string, progress int, message string) error {
+	query := `
+        UPDATE tasks
+        SET progress = $1, progress_message = $2, version = version + 1, updated_at = NOW()
+        WHERE id = $3 AND worker_id = $4 AND status = $5
+    `
+
+	res, err := r.db.ExecContext(ctx, query, progress, message, id, workerID, entity.TaskStatusProcessing)
+	if err != nil {
+		logger.Error("Failed to update task progress", zap.String("id", id), zap.Error(err))
+		return fmt.Errorf("failed to update task progress: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update task progress: %w", err)
+	}
+	if affected == 0 {
+		return entity.ErrLeaseLost
+	}
+
+	return nil
+}
+
+// ExtendLease продлевает аренду выполняемой задачи, если она все еще принадлежит воркеру,
+// и сообщает, запрошена ли отмена задачи. Продление аренды не меняет версию задачи.
+func (r *TaskRepository) ExtendLease(ctx context.Context, id, workerID string, leaseDuration time.Duration) (bool, error) {
+	query := `
+        UPDATE tasks
+        SET locked_until = NOW() + $1::interval
+        WHERE id = $2 AND worker_id = $3 AND status = $4
+        RETURNING cancel_requested
+    `
+
+	var cancelRequested bool
+	err := r.db.GetContext(ctx, &cancelRequested, query,
+		pgInterval(leaseDuration), id, workerID, entity.TaskStatusProcessing)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, entity.ErrLeaseLost
+	}
+	if err != nil {
+		logger.Error("Failed to extend task lease", zap.String("id", id), zap.Error(err))
+		return false, fmt.Errorf("failed to extend task lease: %w", err)
+	}
+
+	return cancelRequested, nil
+}
+
+// RequestCancel отменяет ожидающую, отложенную или ждущую зависимостей задачу или выставляет флаг отмены выполняемой.
+// Ненулевой version задает ожидаемую версию задачи: при несовпадении возвращается entity.ErrConflict.
+// Для задачи в конечном статусе возвращает entity.ErrTaskFinished.
+func (r *TaskRepository) RequestCancel(ctx context.Context, id string, version int) (*entity.Task, error) {
+	query := `
+        UPDATE tasks
+        SET status = CASE WHEN status IN ($2, $5, $6) THEN $3 ELSE status END,
+            error = CASE WHEN status IN ($2, $5, $6) THEN 'task cancelled by request' ELSE error END,
+            cancel_requested = TRUE,
+            version = version + 1,
+            updated_at = NOW()
+        WHERE id = $1 AND status IN ($2, $4, $5, $6) AND ($7 = 0 OR version = $7)
+        RETURNING ` + taskColumns
+
+	var task entity.Task
+	err := r.db.GetContext(ctx, &task, query,
+		id, entity.TaskStatusPending, entity.TaskStatusCancelled, entity.TaskStatusProcessing,
+		entity.TaskStatusScheduled, entity.TaskStatusBlocked, version)
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := r.checkVersion(ctx, id, version); err != nil {
+			return nil, err
+		}
+		return nil, entity.ErrTaskFinished
+	}
+	if err != nil {
+		logger.Error("Failed to request task cancellation", zap.String("id", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to request task cancellation: %w", err)
+	}
+
+	return &task, nil
+}
+
+// Requeue возвращает упавшую или исчерпавшую попытки задачу в очередь со сброшенным счетчиком попыток.
+// Если не все ее зависимости завершены, задача снова ждет их в статусе blocked. Если одна из зависимостей
+// уже не выполнилась, задача так и осталась бы в blocked, поэтому возвращается entity.ErrInvalidTransition.
+// Ненулевой version задает ожидаемую версию задачи, как в RequestCancel.
+// Для задачи в другом статусе возвращает entity.ErrUnexpectedStatus, а если незавершенная задача
+// с тем же unique_key уже есть — entity.ErrDuplicateTask.
+func (r *TaskRepository) Requeue(ctx context.Context, id string, version int) (*entity.Task, error) {
+	query := `
+        UPDATE tasks
+        SET status = CASE
+                WHEN EXISTS (
+                    SELECT 1
+                    FROM task_dependencies d
+                    JOIN tasks p ON p.id = d.depends_on
+                    WHERE d.task_id = tasks.id AND p.status <> $6
+                ) THEN $5
+                ELSE $2
+            END,
+            attempts = 0, error = '', error_code = '', next_run_at = NOW(),
+            cancel_requested = FALSE, version = version + 1, updated_at = NOW()
+        WHERE id = $1 AND status IN ($3, $4) AND ($7 = 0 OR version = $7)
+          AND NOT EXISTS (
+              SELECT 1
+              FROM task_dependencies d
+              JOIN tasks p ON p.id = d.depends_on
+              WHERE d.task_id = tasks.id AND p.status IN ($3, $4, $8)
+          )
+        RETURNING ` + taskColumns
+
+	var task entity.Task
+	err := r.db.GetContext(ctx, &task, query,
+		id, entity.TaskStatusPending, entity.TaskStatusFailed, entity.TaskStatusDead,
+		entity.TaskStatusBlocked, entity.TaskStatusCompleted, version, entity.TaskStatusCancelled)
+	if errors.Is(err, sql.ErrNoRows) {
+		current, err := r.checkVersion(ctx, id, version)
+		if err != nil {
+			return nil, err
+		}
+		if current.Status != entity.TaskStatusFailed && current.Status != entity.TaskStatusDead {
+			return nil, entity.ErrUnexpectedStatus
+		}
+		return nil, r.failedDependencyError(ctx, id)
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return nil, entity.ErrDuplicateTask
+	}
+	if err != nil {
+		logger.Error("Failed to requeue task", zap.String("id", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to requeue task: %w", err)
+	}
+
+	return &task, nil
+}
+
+// checkVersion возвращает текущее состояние задачи, entity.ErrTaskNotFound, если задачи нет, и entity.ErrConflict,
+// если задан ненулевой version и текущая версия задачи от него отличается
+func (r *TaskRepository) checkVersion(ctx context.Context, id string, version int) (*entity.Task, error) {
+	current, err := r.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if version != 0 && current.Version != version {
+		return nil, fmt.Errorf("%w: task version is %d, expected %d", entity.ErrConflict, current.Version, version)
+	}
+	return current, nil
+}
+
+// failedDependencyError возвращает entity.ErrInvalidTransition с зависимостью задачи id, которая не выполнилась
+func (r *TaskRepository) failedDependencyError(ctx context.Context, id string) error {
+	query := `
+        SELECT p.id, p.status
+        FROM task_dependencies d
+        JOIN tasks p ON p.id = d.depends_on
+        WHERE d.task_id = $1 AND p.status IN ($2, $3, $4)
+        LIMIT 1
+    `
+
+	var dependency struct {
+		ID     string            `db:"id"`
+		Status entity.TaskStatus `db:"status"`
+	}
+	err := r.db.GetContext(ctx, &dependency, query,
+		id, entity.TaskStatusFailed, entity.TaskStatusDead, entity.TaskStatusCancelled)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Зависимость успели перезапустить между запросами
+		return fmt.Errorf("%w: task changed concurrently, try again", entity.ErrConflict)
+	}
+	if err != nil {
+		logger.Error("Failed to get failed dependency", zap.String("id", id), zap.Error(err))
+		return fmt.Errorf("failed to get failed dependency: %w", err)
+	}
+
+	return fmt.Errorf("%w: dependency %s is %s, retry it first", entity.ErrInvalidTransition, dependency.ID, dependency.Status)
+}
+
+// ReleaseDependents ставит в очередь задачи, ждущие завершения задачи parentID, у которых завершились
+// все зависимости, и возвращает их. Задача с наступившим временем запуска переходит в pending, остальные — в scheduled.
+// Шаг workflow получает в payload result единственной зависимости, а при нескольких — объект с их результатами
+// по именам шагов.
+func (r *TaskRepository) ReleaseDependents(ctx context.Context, parentID string) ([]*entity.Task, error) {
+	query := `
+        UPDATE tasks t
+        SET status = CASE WHEN t.next_run_at > NOW() THEN $2 ELSE $3 END,
+            next_run_at = GREATEST(t.next_run_at, NOW()),
+            payload = CASE WHEN t.workflow_id IS NULL THEN t.payload ELSE (
+                SELECT CASE WHEN COUNT(*) = 1 THEN (array_agg(p.result))[1]
+                            ELSE jsonb_object_agg(p.workflow_step, p.result)
+                       END
+                FROM task_dependencies d
+                JOIN tasks p ON p.id = d.depends_on
+                WHERE d.task_id = t.id
+            ) END,
+            version = t.version + 1,
+            updated_at = NOW()
+        WHERE t.status = $4
+          AND t.id IN (SELECT task_id FROM task_dependencies WHERE depends_on = $1)
+          AND NOT EXISTS (
+              SELECT 1
+              FROM task_dependencies d
+              JOIN tasks p ON p.id = d.depends_on
+              WHERE d.task_id = t.id AND p.status <> $5
+          )
+        RETURNING ` + taskColumns
+
+	tasks := make([]*entity.Task, 0)
+	err := r.db.SelectContext(ctx, &tasks, query, parentID, entity.TaskStatusScheduled, entity.TaskStatusPending,
+		entity.TaskStatusBlocked, entity.TaskStatusCompleted)
+	if err != nil {
+		logger.Error("Failed to release dependent tasks", zap.String("parent_id", parentID), zap.Error(err))
+		return nil, fmt.Errorf("failed to release dependent tasks: %w", err)
+	}
+
+	return tasks, nil
+}
+
+// FailDependents помечает упавшими все задачи, прямо или транзитивно ждущие задачу parentID,
+// которая не выполнилась, и возвращает их. В ошибке каждой задачи указывается ее прямая зависимость
+// на пути от parentID, а не сама parentID.
+func (r *TaskRepository) FailDependents(ctx context.Context, parentID string) ([]*entity.Task, error) {
+	query := `
+        WITH RECURSIVE dependents(task_id, failed_dependency) AS (
+            SELECT task_id, depends_on FROM task_dependencies WHERE depends_on = $1
+            UNION
+            SELECT d.task_id, d.depends_on FROM task_dependencies d JOIN dependents ON d.depends_on = dependents.task_id
+        )
+        UPDATE tasks
+        SET status = $2, error_code = $3, error = format($5, direct.failed_dependency),
+            version = version + 1, updated_at = NOW()
+        FROM (
+            SELECT DISTINCT ON (task_id) task_id, failed_dependency
+            FROM dependents
+            ORDER BY task_id, failed_dependency
+        ) direct
+        WHERE tasks.id = direct.task_id AND tasks.status = $4
+        RETURNING ` + taskColumns
+
+	tasks, err := r.updateWithWebhooks(ctx, query, parentID, entity.TaskStatusFailed,
+		entity.ErrorCodeDependencyFailed, entity.TaskStatusBlocked, dependencyFailedErrorFormat)
+	if err != nil {
+		logger.Error("Failed to fail dependent tasks", zap.String("parent_id", parentID), zap.Error(err))
+		return nil, fmt.Errorf("failed to fail dependent tasks: %w", err)
+	}
+
+	return tasks, nil
+}
+
+// GetGraph возвращает связный граф зависимостей, в который входит задача id: все задачи,
+// связанные с ней цепочками зависимостей в любом направлении, и ребра между ними
+func (r *TaskRepository) GetGraph(ctx context.Context, id string) (*entity.TaskGraph, error) {
+	query := `
+        WITH RECURSIVE component(id) AS (
+            SELECT $1::uuid
+            UNION
+            SELECT CASE WHEN d.task_id = c.id THEN d.depends_on ELSE d.task_id END
+            FROM task_dependencies d
+            JOIN component c ON d.task_id = c.id OR d.depends_on = c.id
+        )
+        SELECT id, type, status, progress
+        FROM tasks
+        WHERE id IN (SELECT id FROM component)
+        ORDER BY created_at
+    `
+
+	graph := &entity.TaskGraph{
+		Nodes: make([]*entity.TaskGraphNode, 0),
+		Edges: make([]*entity.TaskDependency, 0),
+	}
+	if err := r.db.SelectContext(ctx, &graph.Nodes, query, id); err != nil {
+		logger.Error("Failed to get task graph", zap.String("id", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to get task graph: %w", err)
+	}
+	if len(graph.Nodes) == 0 {
+		return nil, entity.ErrTaskNotFound
+	}
+
+	ids := make([]string, 0, len(graph.Nodes))
+	for _, node := range graph.Nodes {
+		ids = append(ids, node.ID)
+	}
+	err := r.db.SelectContext(ctx, &graph.Edges, `
+        SELECT task_id, depends_on
+        FROM task_dependencies
+        WHERE task_id = ANY($1::uuid[])
+        ORDER BY task_id, depends_on
+    `, pq.Array(ids))
+	if err != nil {
+		logger.Error("Failed to get task dependencies", zap.String("id", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to get task dependencies: %w", err)
+	}
+
+	return graph, nil
+}
+
+// CountChildren возвращает число дочерних задач parentID в каждом статусе
+func (r *TaskRepository) CountChildren(ctx context.Context, parentID string) (map[entity.TaskStatus]int, error) {
+	query := `
+        SELECT status, COUNT(*) AS count
+        FROM tasks
+        WHERE parent_id = $1
+        GROUP BY status
+    `
+
+	var rows []struct {
+		Status entity.TaskStatus `db:"status"`
+		Count  int               `db:"count"`
+	}
+	if err := r.db.SelectContext(ctx, &rows, query, parentID); err != nil {
+		logger.Error("Failed to count child tasks", zap.String("parent_id", parentID), zap.Error(err))
+		return nil, fmt.Errorf("failed to count child tasks: %w", err)
+	}
+
+	counts := make(map[entity.TaskStatus]int, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+
+	return counts, nil
+}
+
+// CompleteParent завершает задачу id, которая ждет дочерние задачи в статусе blocked, если все они уже
+// в конечном статусе: задача выполнена, если выполнены все дочерние задачи, иначе падает с кодом child_failed.
+// В result сохраняются результат обработчика и результаты дочерних задач в порядке создания.
+// Если задача не ждет дочерние задачи (в том числе уже завершена или отменена) или не все они завершены, возвращает false.
+func (r *TaskRepository) CompleteParent(ctx context.Context, id string) (*entity.Task, bool, error) {
+	query := `
+        WITH children AS (
+            SELECT COUNT(*) AS total,
+                   COUNT(*) FILTER (WHERE status <> $3) AS not_completed,
+                   COALESCE(jsonb_agg(
+                       CASE WHEN error = '' THEN jsonb_build_object('id', id, 'status', status, 'result', result)
+                            ELSE jsonb_build_object('id', id, 'status', status, 'result', result, 'error', error)
+                       END
+                       ORDER BY created_at, id
+                   ), '[]'::jsonb) AS results
+            FROM tasks
+            WHERE parent_id = $1
+        )
+        UPDATE tasks
+        SET status = CASE WHEN children.not_completed = 0 THEN $3 ELSE $4 END,
+            error_code = CASE WHEN children.not_completed = 0 THEN '' ELSE $5 END,
+            error = CASE
+                WHEN children.not_completed = 0 THEN ''
+                ELSE children.not_completed || ' child tasks did not complete'
+            END,
+            result = jsonb_build_object('result', tasks.result, 'children', children.results),
+            progress = CASE WHEN children.not_completed = 0 THEN 100 ELSE tasks.progress END,
+            version = tasks.version + 1,
+            updated_at = NOW()
+        FROM children
+        WHERE tasks.id = $1 AND tasks.status = $2 AND children.total > 0
+          AND NOT EXISTS (SELECT 1 FROM tasks c WHERE c.parent_id = $1 AND c.status NOT IN ($3, $4, $6, $7))
+        RETURNING ` + taskColumns
+
+	tasks, err := r.updateWithWebhooks(ctx, query, id, entity.TaskStatusBlocked, entity.TaskStatusCompleted,
+		entity.TaskStatusFailed, entity.ErrorCodeChildFailed, entity.TaskStatusDead, entity.TaskStatusCancelled)
+	if err != nil {
+		logger.Error("Failed to complete parent task", zap.String("id", id), zap.Error(err))
+		return nil, false, fmt.Errorf("failed to complete parent task: %w", err)
+	}
+	if len(tasks) == 0 {
+		return nil, false, nil
+	}
+
+	return tasks[0], true, nil
+}
+
+// CancelChildren отменяет незавершенные дочерние задачи задачи parentID и возвращает их:
+// ожидающие переводятся в статус cancelled сразу, а выполняемым выставляется флаг отмены, как в RequestCancel
+func (r *TaskRepository) CancelChildren(ctx context.Context, parentID string) ([]*entity.Task, error) {
+	query := `
+        UPDATE tasks
+        SET status = CASE WHEN status IN ($2, $5, $6) THEN $3 ELSE status END,
+            error = CASE WHEN status IN ($2, $5, $6) THEN 'parent task cancelled' ELSE error END,
+            cancel_requested = TRUE,
+            version = version + 1,
+            updated_at = NOW()
+        WHERE parent_id = $1 AND status IN ($2, $4, $5, $6)
+        RETURNING ` + taskColumns
+
+	tasks := make([]*entity.Task, 0)
+	err := r.db.SelectContext(ctx, &tasks, query, parentID, entity.TaskStatusPending, entity.TaskStatusCancelled,
+		entity.TaskStatusProcessing, entity.TaskStatusScheduled, entity.TaskStatusBlocked)
+	if err != nil {
+		logger.Error("Failed to cancel child tasks", zap.String("parent_id", parentID), zap.Error(err))
+		return nil, fmt.Errorf("failed to cancel child tasks: %w", err)
+	}
+
+	return tasks, nil
+}
+
+// DetachChildren отвязывает от задачи parentID все ее дочерние задачи, чтобы CompleteParent следующей попытки
+// не учитывал их, и отменяет незавершенные из них, как CancelChildren. Возвращает отмененные задачи.
+func (r *TaskRepository) DetachChildren(ctx context.Context, parentID string) ([]*entity.Task, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		logger.Error("Failed to begin detach transaction", zap.String("parent_id", parentID), zap.Error(err))
+		return nil, fmt.Errorf("failed to detach child tasks: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	query := `
+        UPDATE tasks
+        SET status = CASE WHEN status IN ($2, $5, $6) THEN $3 ELSE status END,
+            error = CASE WHEN status IN ($2, $5, $6) THEN 'parent task attempt failed' ELSE error END,
+            cancel_requested = TRUE,
+            parent_id = NULL,
+            version = version + 1,
+            updated_at = NOW()
+        WHERE parent_id = $1 AND status IN ($2, $4, $5, $6)
+        RETURNING ` + taskColumns
+
+	tasks := make([]*entity.Task, 0)
+	err = sqlx.SelectContext(ctx, tx, &tasks, query, parentID, entity.TaskStatusPending, entity.TaskStatusCancelled,
+		entity.TaskStatusProcessing, entity.TaskStatusScheduled, entity.TaskStatusBlocked)
+	if err != nil {
+		logger.Error("Failed to cancel child tasks", zap.String("parent_id", parentID), zap.Error(err))
+		return nil, fmt.Errorf("failed to detach child tasks: %w", err)
+	}
+
+	query = `
+        UPDATE tasks
+        SET parent_id = NULL, version = version + 1, updated_at = NOW()
+        WHERE parent_id = $1
+    `
+	if _, err := tx.ExecContext(ctx, query, parentID); err != nil {
+		logger.Error("Failed to detach child tasks", zap.String("parent_id", parentID), zap.Error(err))
+		return nil, fmt.Errorf("failed to detach child tasks: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Failed to commit detach transaction", zap.String("parent_id", parentID), zap.Error(err))
+		return nil, fmt.Errorf("failed to detach child tasks: %w", err)
+	}
+
+	return tasks, nil
+}
+
+// PromoteDue переводит отложенные задачи, время запуска которых наступило, в статус pending
+func (r *TaskRepository) PromoteDue(ctx context.Context) ([]*entity.Task, error) {
+	query := `
+        UPDATE tasks
+        SET status = $1, version = version + 1, updated_at = NOW()
+        WHERE status = $2 AND next_run_at <= NOW()
+        RETURNING ` + taskColumns
+
+	tasks := make([]*entity.Task, 0)
+	err := r.db.SelectContext(ctx, &tasks, query, entity.TaskStatusPending, entity.TaskStatusScheduled)
+	if err != nil {
+		logger.Error("Failed to promote scheduled tasks", zap.Error(err))
+		return nil, fmt.Errorf("failed to promote scheduled tasks: %w", err)
+	}
+
+	return tasks, nil
+}
+
+// ReleaseExpired возвращает в очередь задачи с истекшей арендой и записывает неудачную попытку в историю.
+// Задачи, исчерпавшие max_attempts попыток, переводятся в статус dead,
+// а задачи с запрошенной отменой — в статус cancelled.
+func (r *TaskRepository) ReleaseExpired(ctx context.Context) ([]*entity.Task, error) {
+	query := `
+        UPDATE tasks
+        SET status = CASE
+                WHEN cancel_requested THEN $4
+                WHEN attempts >= max_attempts THEN $1
+                ELSE $2
+            END,
+            error = CASE
+                WHEN cancel_requested THEN 'task cancelled by request'
+                WHEN attempts >= max_attempts THEN 'lease expired after ' || attempts || ' attempts'
+                ELSE error
+            END,
+            attempt_history = attempt_history || jsonb_build_array(jsonb_build_object(
+                'attempt', attempts,
+                'worker_id', worker_id,
+                'started_at', COALESCE(started_at, updated_at),
+                'finished_at', NOW(),
+                'error', 'lease expired'
+            )),
+            next_run_at = NOW(),
+            worker_id = '', locked_until = NULL, version = version + 1, updated_at = NOW()
+        WHERE status = $3 AND locked_until < NOW()
+        RETURNING ` + taskColumns
+
+	tasks, err := r.updateWithWebhooks(ctx, query,
+		entity.TaskStatusDead, entity.TaskStatusPending, entity.TaskStatusProcessing, entity.TaskStatusCancelled)
+	if err != nil {
+		logger.Error("Failed to release expired tasks", zap.Error(err))
+		return nil, fmt.Errorf("failed to release expired tasks: %w", err)
+	}
+
+	return tasks, nil
+}
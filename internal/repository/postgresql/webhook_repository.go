@@ -0,0 +1,153 @@
+package postgresql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Egorpalan/workmate-test/internal/entity"
+	"github.com/Egorpalan/workmate-test/pkg/logger"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// webhookColumns перечисляет колонки, из которых собирается entity.WebhookDelivery
+const webhookColumns = "id, task_id, url, event, payload, status, attempts, max_attempts, next_attempt_at, " +
+	"last_status_code, last_error, delivered_at, created_at, updated_at"
+
+type WebhookRepository struct {
+	db *sqlx.DB
+}
+
+// NewWebhookRepository создает новый экземпляр WebhookRepository
+func NewWebhookRepository(db *sqlx.DB) *WebhookRepository {
+	return &WebhookRepository{
+		db: db,
+	}
+}
+
+// insertWebhookDeliveries сохраняет доставки вебхуков о завершении задач tasks, которым они нужны.
+// Вызывается в транзакции, меняющей статус задач, поэтому вебхук не теряется при падении сервиса между записями.
+func insertWebhookDeliveries(ctx context.Context, q sqlx.QueryerContext, tasks []*entity.Task, maxAttempts int) error {
+	for _, task := range tasks {
+		delivery, err := entity.NewWebhookDelivery(task, maxAttempts)
+		if err != nil {
+			logger.Error("Failed to build webhook delivery", zap.String("task_id", task.ID), zap.Error(err))
+			return err
+		}
+		if delivery == nil {
+			continue
+		}
+		if err := insertWebhookDelivery(ctx, q, delivery); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// insertWebhookDelivery сохраняет новую доставку вебхука
+func insertWebhookDelivery(ctx context.Context, q sqlx.QueryerContext, delivery *entity.WebhookDelivery) error {
+	query := `
+        INSERT INTO webhook_deliveries (task_id, url, event, payload, status, max_attempts)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id, next_attempt_at, created_at, updated_at
+    `
+
+	row := q.QueryRowxContext(
+		ctx,
+		query,
+		delivery.TaskID,
+		delivery.URL,
+		delivery.Event,
+		delivery.Payload,
+		delivery.Status,
+		delivery.MaxAttempts,
+	)
+
+	err := row.Scan(&delivery.ID, &delivery.NextAttemptAt, &delivery.CreatedAt, &delivery.UpdatedAt)
+	if err != nil {
+		logger.Error("Failed to create webhook delivery", zap.String("task_id", delivery.TaskID), zap.Error(err))
+		return fmt.Errorf("failed to create webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+// ClaimDue забирает до limit ожидающих доставок, время попытки которых наступило, и увеличивает счетчик попыток.
+// Следующая попытка откладывается на leaseDuration, поэтому если реплика упадет во время отправки,
+// доставку повторит другая реплика, а пока отправка идет, ее не заберет никто другой.
+func (r *WebhookRepository) ClaimDue(
+	ctx context.Context,
+	limit int,
+	leaseDuration time.Duration,
+) ([]*entity.WebhookDelivery, error) {
+	query := `
+        UPDATE webhook_deliveries
+        SET attempts = attempts + 1, next_attempt_at = NOW() + $3::interval, updated_at = NOW()
+        WHERE id IN (
+            SELECT id
+            FROM webhook_deliveries
+            WHERE status = $1 AND next_attempt_at <= NOW()
+            ORDER BY next_attempt_at
+            FOR UPDATE SKIP LOCKED
+            LIMIT $2
+        )
+        RETURNING ` + webhookColumns
+
+	deliveries := make([]*entity.WebhookDelivery, 0)
+	err := r.db.SelectContext(ctx, &deliveries, query, entity.WebhookStatusPending, limit, pgInterval(leaseDuration))
+	if err != nil {
+		logger.Error("Failed to claim webhook deliveries", zap.Error(err))
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// Update сохраняет результат попытки доставки
+func (r *WebhookRepository) Update(ctx context.Context, delivery *entity.WebhookDelivery) error {
+	query := `
+        UPDATE webhook_deliveries
+        SET status = $1, next_attempt_at = $2, last_status_code = $3, last_error = $4,
+            delivered_at = $5, updated_at = NOW()
+        WHERE id = $6
+        RETURNING updated_at
+    `
+
+	row := r.db.QueryRowContext(
+		ctx,
+		query,
+		delivery.Status,
+		delivery.NextAttemptAt,
+		delivery.LastStatusCode,
+		delivery.LastError,
+		delivery.DeliveredAt,
+		delivery.ID,
+	)
+
+	if err := row.Scan(&delivery.UpdatedAt); err != nil {
+		logger.Error("Failed to update webhook delivery", zap.String("id", delivery.ID), zap.Error(err))
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+// ListByTask возвращает доставки вебхуков задачи, начиная с самой ранней
+func (r *WebhookRepository) ListByTask(ctx context.Context, taskID string) ([]*entity.WebhookDelivery, error) {
+	query := `
+        SELECT ` + webhookColumns + `
+        FROM webhook_deliveries
+        WHERE task_id = $1
+        ORDER BY created_at
+    `
+
+	deliveries := make([]*entity.WebhookDelivery, 0)
+	if err := r.db.SelectContext(ctx, &deliveries, query, taskID); err != nil {
+		logger.Error("Failed to list webhook deliveries", zap.String("task_id", taskID), zap.Error(err))
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
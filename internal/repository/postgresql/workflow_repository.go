@@ -0,0 +1,148 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Egorpalan/workmate-test/internal/entity"
+	"github.com/Egorpalan/workmate-test/pkg/logger"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+type WorkflowRepository struct {
+	db *sqlx.DB
+}
+
+// NewWorkflowRepository создает новый экземпляр WorkflowRepository
+func NewWorkflowRepository(db *sqlx.DB) *WorkflowRepository {
+	return &WorkflowRepository{
+		db: db,
+	}
+}
+
+// Create создает workflow и задачи его шагов в одной транзакции; tasks[i] — задача шага workflow.Steps[i].
+// Шаги должны быть упорядочены так, чтобы After ссылался только на предыдущие шаги:
+// задачи вставляются по порядку, и зависимости каждой из них уже существуют.
+func (r *WorkflowRepository) Create(ctx context.Context, workflow *entity.Workflow, tasks []*entity.Task) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		logger.Error("Failed to begin workflow transaction", zap.Error(err))
+		return fmt.Errorf("failed to create workflow: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	query := `
+        INSERT INTO workflows (name, input, steps)
+        VALUES ($1, $2, $3)
+        RETURNING id, created_at
+    `
+	row := tx.QueryRowxContext(ctx, query, workflow.Name, workflow.Input, workflow.Steps)
+	if err := row.Scan(&workflow.ID, &workflow.CreatedAt); err != nil {
+		logger.Error("Failed to create workflow", zap.Error(err))
+		return fmt.Errorf("failed to create workflow: %w", err)
+	}
+
+	taskIDs := make(map[string]string, len(tasks))
+	for i, task := range tasks {
+		step := workflow.Steps[i]
+		task.WorkflowID = &workflow.ID
+		task.WorkflowStep = step.Name
+		task.DependsOn = make([]string, 0, len(step.After))
+		for _, name := range step.After {
+			task.DependsOn = append(task.DependsOn, taskIDs[name])
+		}
+
+		if _, err := insertTask(ctx, tx, task); err != nil {
+			return err
+		}
+		taskIDs[step.Name] = task.ID
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Failed to commit workflow transaction", zap.Error(err))
+		return fmt.Errorf("failed to create workflow: %w", err)
+	}
+
+	workflow.Tasks = tasks
+	return nil
+}
+
+// GetByID возвращает workflow по его ID вместе с задачами его шагов в порядке шагов
+func (r *WorkflowRepository) GetByID(ctx context.Context, id string) (*entity.Workflow, error) {
+	var workflow entity.Workflow
+	err := r.db.GetContext(ctx, &workflow, `SELECT id, name, input, steps, created_at FROM workflows WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, entity.ErrWorkflowNotFound
+	}
+	if err != nil {
+		logger.Error("Failed to get workflow by ID", zap.String("id", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to get workflow by id: %w", err)
+	}
+
+	query := `
+        SELECT ` + taskColumns + `
+        FROM tasks
+        WHERE workflow_id = $1
+    `
+
+	var tasks []*entity.Task
+	if err := r.db.SelectContext(ctx, &tasks, query, id); err != nil {
+		logger.Error("Failed to get workflow tasks", zap.String("id", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to get workflow tasks: %w", err)
+	}
+
+	byStep := make(map[string]*entity.Task, len(tasks))
+	for _, task := range tasks {
+		byStep[task.WorkflowStep] = task
+	}
+	workflow.Tasks = make([]*entity.Task, 0, len(tasks))
+	for _, step := range workflow.Steps {
+		if task, ok := byStep[step.Name]; ok {
+			workflow.Tasks = append(workflow.Tasks, task)
+		}
+	}
+
+	return &workflow, nil
+}
+
+// Cancel отменяет все незавершенные задачи workflow и их потомков по parent_id одним запросом и возвращает их:
+// ожидающие, отложенные и ждущие зависимостей переводятся в cancelled, выполняемым выставляется флаг отмены.
+// Если незавершенных задач нет, возвращает entity.ErrTaskFinished.
+func (r *WorkflowRepository) Cancel(ctx context.Context, id string) ([]*entity.Task, error) {
+	query := `
+        WITH RECURSIVE workflow_tasks(id) AS (
+            SELECT id FROM tasks WHERE workflow_id = $1
+            UNION
+            SELECT t.id FROM tasks t JOIN workflow_tasks w ON t.parent_id = w.id
+        )
+        UPDATE tasks
+        SET status = CASE WHEN status IN ($2, $5, $6) THEN $3 ELSE status END,
+            error = CASE WHEN status IN ($2, $5, $6) THEN 'workflow cancelled by request' ELSE error END,
+            cancel_requested = TRUE,
+            version = version + 1,
+            updated_at = NOW()
+        WHERE id IN (SELECT id FROM workflow_tasks) AND status IN ($2, $4, $5, $6)
+        RETURNING ` + taskColumns
+
+	tasks := make([]*entity.Task, 0)
+	err := r.db.SelectContext(ctx, &tasks, query,
+		id, entity.TaskStatusPending, entity.TaskStatusCancelled, entity.TaskStatusProcessing,
+		entity.TaskStatusScheduled, entity.TaskStatusBlocked)
+	if err != nil {
+		logger.Error("Failed to cancel workflow", zap.String("id", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to cancel workflow: %w", err)
+	}
+	if len(tasks) == 0 {
+		if _, err := r.GetByID(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, entity.ErrTaskFinished
+	}
+
+	return tasks, nil
+}
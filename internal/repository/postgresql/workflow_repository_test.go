@@ -0,0 +1,71 @@
+package postgresql
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/Egorpalan/workmate-test/internal/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWorkflowPayloadPassing тестирует передачу результатов шагов дальше по workflow: шаг с одним
+// предшественником получает его result, а шаг с несколькими — объект с их результатами по именам шагов
+func TestWorkflowPayloadPassing(t *testing.T) {
+	db := testDB(t)
+	taskRepo := NewTaskRepository(db, 3)
+	workflowRepo := NewWorkflowRepository(db)
+
+	workflow := &entity.Workflow{
+		Name:  "report",
+		Input: json.RawMessage(`{"month":"2024-05"}`),
+		Steps: entity.WorkflowSteps{
+			{Name: "fetch", Type: "test", After: []string{}},
+			{Name: "render", Type: "test", After: []string{"fetch"}},
+			{Name: "notify", Type: "test", After: []string{"fetch", "render"}},
+		},
+	}
+	tasks := make([]*entity.Task, 0, len(workflow.Steps))
+	for i := range workflow.Steps {
+		payload := json.RawMessage(`{}`)
+		if i == 0 {
+			payload = workflow.Input
+		}
+		tasks = append(tasks, &entity.Task{
+			Type:        "test",
+			Queue:       "default",
+			Status:      entity.TaskStatusPending,
+			Payload:     payload,
+			Result:      json.RawMessage(`{}`),
+			MaxAttempts: 3,
+		})
+	}
+
+	if err := workflowRepo.Create(context.Background(), workflow, tasks); err != nil {
+		t.Fatalf("failed to create workflow: %v", err)
+	}
+	fetch, render, notify := tasks[0], tasks[1], tasks[2]
+	assert.Equal(t, entity.TaskStatusPending, fetch.Status)
+	assert.Equal(t, entity.TaskStatusBlocked, render.Status)
+	assert.Equal(t, entity.TaskStatusBlocked, notify.Status)
+
+	setTestTaskStatus(t, db, fetch.ID, entity.TaskStatusCompleted, `{"rows":10}`)
+	released, err := taskRepo.ReleaseDependents(context.Background(), fetch.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, released, 1) {
+		assert.Equal(t, render.ID, released[0].ID)
+		assert.JSONEq(t, `{"rows":10}`, string(released[0].Payload))
+	}
+
+	setTestTaskStatus(t, db, render.ID, entity.TaskStatusCompleted, `{"file":"report.pdf"}`)
+	released, err = taskRepo.ReleaseDependents(context.Background(), render.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, released, 1) {
+		assert.Equal(t, notify.ID, released[0].ID)
+		assert.JSONEq(t, `{"fetch":{"rows":10},"render":{"file":"report.pdf"}}`, string(released[0].Payload))
+	}
+
+	stored, err := workflowRepo.GetByID(context.Background(), workflow.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, workflow.Steps, stored.Steps)
+}
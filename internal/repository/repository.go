@@ -2,24 +2,90 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/Egorpalan/workmate-test/internal/entity"
 )
 
 type TaskRepository interface {
-	Create(ctx context.Context, task *entity.Task) error
+	Create(ctx context.Context, task *entity.Task) (bool, error)
+	CreateMany(ctx context.Context, tasks []*entity.Task) (string, error)
+	CreateIdempotent(
+		ctx context.Context,
+		task *entity.Task,
+		key, requestHash string,
+		window time.Duration,
+	) (*entity.IdempotencyKey, bool, error)
+	DeleteExpiredIdempotencyKeys(ctx context.Context, window time.Duration) (int64, error)
+	HasIdempotencyKey(ctx context.Context, key string, window time.Duration) (bool, error)
+	HasUnfinished(ctx context.Context, taskType, uniqueKey string) (bool, error)
 	GetByID(ctx context.Context, id string) (*entity.Task, error)
-	Update(ctx context.Context, task *entity.Task) error
-	List(ctx context.Context, limit, offset int) ([]*entity.Task, error)
+	Update(ctx context.Context, task *entity.Task, from entity.TaskStatus) error
+	List(ctx context.Context, filter entity.TaskFilter) ([]*entity.Task, error)
+	ClaimNext(ctx context.Context, opts entity.ClaimOptions) (*entity.Task, error)
+	ExtendLease(ctx context.Context, id, workerID string, leaseDuration time.Duration) (bool, error)
+	UpdateProgress(ctx context.Context, id, workerID string, progress int, message string) error
+	RequestCancel(ctx context.Context, id string, version int) (*entity.Task, error)
+	Requeue(ctx context.Context, id string, version int) (*entity.Task, error)
+	ReleaseDependents(ctx context.Context, parentID string) ([]*entity.Task, error)
+	FailDependents(ctx context.Context, parentID string) ([]*entity.Task, error)
+	GetGraph(ctx context.Context, id string) (*entity.TaskGraph, error)
+	CountChildren(ctx context.Context, parentID string) (map[entity.TaskStatus]int, error)
+	CompleteParent(ctx context.Context, id string) (*entity.Task, bool, error)
+	CancelChildren(ctx context.Context, parentID string) ([]*entity.Task, error)
+	DetachChildren(ctx context.Context, parentID string) ([]*entity.Task, error)
+	PromoteDue(ctx context.Context) ([]*entity.Task, error)
+	ReleaseExpired(ctx context.Context) ([]*entity.Task, error)
+	CountByStatus(ctx context.Context, status entity.TaskStatus) (int, error)
+	QueueStats(ctx context.Context) ([]*entity.QueueStats, error)
+}
+
+// TaskEventBus доставляет события изменения задач всем репликам сервиса
+type TaskEventBus interface {
+	Publish(ctx context.Context, event entity.TaskEvent) error
+	Listen(ctx context.Context, handler func(entity.TaskEvent)) error
+}
+
+type ScheduleRepository interface {
+	Create(ctx context.Context, schedule *entity.Schedule) error
+	GetByID(ctx context.Context, id string) (*entity.Schedule, error)
+	Update(ctx context.Context, schedule *entity.Schedule) error
+	Delete(ctx context.Context, id string) error
+	List(ctx context.Context, limit, offset int) ([]*entity.Schedule, error)
+	ListDue(ctx context.Context, now time.Time, limit int) ([]*entity.Schedule, error)
+	Fire(ctx context.Context, schedule *entity.Schedule, nextRunAt time.Time) (bool, error)
+}
+
+type WebhookRepository interface {
+	ClaimDue(ctx context.Context, limit int, leaseDuration time.Duration) ([]*entity.WebhookDelivery, error)
+	Update(ctx context.Context, delivery *entity.WebhookDelivery) error
+	ListByTask(ctx context.Context, taskID string) ([]*entity.WebhookDelivery, error)
+}
+
+type WorkflowRepository interface {
+	Create(ctx context.Context, workflow *entity.Workflow, tasks []*entity.Task) error
+	GetByID(ctx context.Context, id string) (*entity.Workflow, error)
+	Cancel(ctx context.Context, id string) ([]*entity.Task, error)
 }
 
 type Repository struct {
-	Task TaskRepository
+	Task     TaskRepository
+	Schedule ScheduleRepository
+	Webhook  WebhookRepository
+	Workflow WorkflowRepository
 }
 
 // NewRepository создает новый экземпляр всех репозиториев
-func NewRepository(task TaskRepository) *Repository {
+func NewRepository(
+	task TaskRepository,
+	schedule ScheduleRepository,
+	webhook WebhookRepository,
+	workflow WorkflowRepository,
+) *Repository {
 	return &Repository{
-		Task: task,
+		Task:     task,
+		Schedule: schedule,
+		Webhook:  webhook,
+		Workflow: workflow,
 	}
 }
@@ -0,0 +1,45 @@
+package usecase
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// runPeriodically запускает fn сразу и затем с интервалом interval, пока не отменен контекст.
+// Горутина учитывается в wg, чтобы Stop дожидался ее завершения.
+func runPeriodically(ctx context.Context, wg *sync.WaitGroup, interval time.Duration, fn func(ctx context.Context)) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			fn(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// waitGroup ждет завершения wg, но не дольше, чем живет ctx
+func waitGroup(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,34 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Egorpalan/workmate-test/internal/entity"
+)
+
+// ErrNotInTask возвращается при попытке создать дочерние задачи вне обработчика задачи
+var ErrNotInTask = errors.New("child tasks can only be spawned from a task handler")
+
+type childSpawnerKey struct{}
+
+// childSpawner создает дочерние задачи задачи, в контексте которой вызван обработчик
+type childSpawner func(ctx context.Context, specs []entity.TaskSpec) ([]*entity.Task, error)
+
+// withChildSpawner возвращает контекст обработчика задачи с функцией создания дочерних задач
+func withChildSpawner(ctx context.Context, spawn childSpawner) context.Context {
+	return context.WithValue(ctx, childSpawnerKey{}, spawn)
+}
+
+// SpawnChildren создает дочерние задачи из обработчика задачи и возвращает их. Когда обработчик успешно
+// завершится, задача будет ждать дочерние задачи в статусе blocked и завершится вместе с последней из них;
+// ее result объединит результат обработчика и результаты дочерних задач.
+// Дочерние задачи, созданные в упавшей попытке, не отменяются — для повторов удобно задавать им unique_key.
+// Отмена задачи отменяет и ее незавершенные дочерние задачи.
+func SpawnChildren(ctx context.Context, specs ...entity.TaskSpec) ([]*entity.Task, error) {
+	spawn, ok := ctx.Value(childSpawnerKey{}).(childSpawner)
+	if !ok {
+		return nil, ErrNotInTask
+	}
+	return spawn(ctx, specs)
+}
@@ -0,0 +1,69 @@
+package usecase
+
+import (
+	"sync"
+
+	"github.com/Egorpalan/workmate-test/internal/entity"
+)
+
+// eventSubscriberBuffer — размер буфера канала подписчика; события для отстающего подписчика отбрасываются
+const eventSubscriberBuffer = 64
+
+// EventBroker рассылает события задач подписчикам внутри процесса
+type EventBroker struct {
+	mu          sync.RWMutex
+	subscribers map[*eventSubscriber]struct{}
+}
+
+type eventSubscriber struct {
+	taskID string
+	events chan entity.TaskEvent
+}
+
+// NewEventBroker создает брокер без подписчиков
+func NewEventBroker() *EventBroker {
+	return &EventBroker{
+		subscribers: make(map[*eventSubscriber]struct{}),
+	}
+}
+
+// Subscribe подписывается на события задачи taskID или на события всех задач, если taskID пуст.
+// Возвращает канал событий и функцию отписки, которая закрывает канал.
+func (b *EventBroker) Subscribe(taskID string) (<-chan entity.TaskEvent, func()) {
+	sub := &eventSubscriber{
+		taskID: taskID,
+		events: make(chan entity.TaskEvent, eventSubscriberBuffer),
+	}
+
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, sub)
+			close(sub.events)
+			b.mu.Unlock()
+		})
+	}
+
+	return sub.events, unsubscribe
+}
+
+// Publish передает событие всем подходящим подписчикам, не блокируясь на медленных
+func (b *EventBroker) Publish(event entity.TaskEvent) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subscribers {
+		if sub.taskID != "" && sub.taskID != event.TaskID {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+		}
+	}
+}
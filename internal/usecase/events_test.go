@@ -0,0 +1,44 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/Egorpalan/workmate-test/internal/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestEventBroker_FiltersByTask тестирует доставку событий подписчикам конкретной задачи и всех задач
+func TestEventBroker_FiltersByTask(t *testing.T) {
+	broker := NewEventBroker()
+
+	taskEvents, unsubscribeTask := broker.Subscribe("task-1")
+	defer unsubscribeTask()
+	allEvents, unsubscribeAll := broker.Subscribe("")
+	defer unsubscribeAll()
+
+	broker.Publish(entity.TaskEvent{TaskID: "task-2", Status: entity.TaskStatusPending})
+	broker.Publish(entity.TaskEvent{TaskID: "task-1", Status: entity.TaskStatusCompleted})
+
+	assert.Equal(t, "task-1", (<-taskEvents).TaskID)
+	assert.Equal(t, "task-2", (<-allEvents).TaskID)
+	assert.Equal(t, "task-1", (<-allEvents).TaskID)
+	assert.Empty(t, taskEvents)
+}
+
+// TestEventBroker_Unsubscribe тестирует закрытие канала при отписке и отсутствие блокировки на медленном подписчике
+func TestEventBroker_Unsubscribe(t *testing.T) {
+	broker := NewEventBroker()
+
+	slow, unsubscribeSlow := broker.Subscribe("")
+	for i := 0; i < eventSubscriberBuffer+10; i++ {
+		broker.Publish(entity.TaskEvent{TaskID: "task"})
+	}
+	assert.Len(t, slow, eventSubscriberBuffer)
+
+	unsubscribeSlow()
+	unsubscribeSlow()
+
+	for range slow {
+	}
+	broker.Publish(entity.TaskEvent{TaskID: "task"})
+}
@@ -0,0 +1,34 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrInvalidProgress возвращается, если процент выполнения задачи вне диапазона от 0 до 100
+var ErrInvalidProgress = errors.New("progress must be between 0 and 100")
+
+type progressKey struct{}
+
+// progressReporter сохраняет прогресс задачи, в контексте которой вызван обработчик
+type progressReporter func(ctx context.Context, percent int, message string) error
+
+// withProgressReporter возвращает контекст обработчика задачи с функцией публикации прогресса
+func withProgressReporter(ctx context.Context, report progressReporter) context.Context {
+	return context.WithValue(ctx, progressKey{}, report)
+}
+
+// ReportProgress публикует процент выполнения и сообщение о ходе задачи из ее обработчика.
+// Вне обработчика задачи вызов ничего не делает. Возвращает entity.ErrLeaseLost,
+// если задача больше не принадлежит этому воркеру.
+func ReportProgress(ctx context.Context, percent int, message string) error {
+	if percent < 0 || percent > 100 {
+		return ErrInvalidProgress
+	}
+
+	report, ok := ctx.Value(progressKey{}).(progressReporter)
+	if !ok {
+		return nil
+	}
+	return report(ctx, percent, message)
+}
@@ -0,0 +1,67 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ErrUnknownTaskType возвращается, когда для типа задачи не зарегистрирован обработчик
+var ErrUnknownTaskType = errors.New("unknown task type")
+
+// TaskHandlerRegistry хранит обработчики задач по имени типа (например, "report.generate")
+type TaskHandlerRegistry struct {
+	mu       sync.RWMutex
+	handlers map[string]LongRunningTask
+}
+
+// NewTaskHandlerRegistry создает пустой реестр обработчиков
+func NewTaskHandlerRegistry() *TaskHandlerRegistry {
+	return &TaskHandlerRegistry{
+		handlers: make(map[string]LongRunningTask),
+	}
+}
+
+// Register регистрирует обработчик для типа задачи; повторная регистрация типа запрещена
+func (r *TaskHandlerRegistry) Register(taskType string, handler LongRunningTask) error {
+	if taskType == "" {
+		return errors.New("task type must not be empty")
+	}
+	if handler == nil {
+		return fmt.Errorf("handler for task type %q must not be nil", taskType)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.handlers[taskType]; exists {
+		return fmt.Errorf("handler for task type %q is already registered", taskType)
+	}
+	r.handlers[taskType] = handler
+
+	return nil
+}
+
+// Get возвращает обработчик для типа задачи
+func (r *TaskHandlerRegistry) Get(taskType string) (LongRunningTask, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	handler, ok := r.handlers[taskType]
+	return handler, ok
+}
+
+// Types возвращает отсортированный список зарегистрированных типов задач
+func (r *TaskHandlerRegistry) Types() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	types := make([]string, 0, len(r.handlers))
+	for taskType := range r.handlers {
+		types = append(types, taskType)
+	}
+	sort.Strings(types)
+
+	return types
+}
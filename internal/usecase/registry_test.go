@@ -0,0 +1,30 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestTaskHandlerRegistry тестирует регистрацию и получение обработчиков по типу
+func TestTaskHandlerRegistry(t *testing.T) {
+	registry := NewTaskHandlerRegistry()
+	handler := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		return nil, nil
+	}
+
+	assert.NoError(t, registry.Register("report.generate", handler))
+	assert.NoError(t, registry.Register("email.send", handler))
+	assert.Error(t, registry.Register("email.send", handler))
+	assert.Error(t, registry.Register("", handler))
+
+	_, ok := registry.Get("report.generate")
+	assert.True(t, ok)
+
+	_, ok = registry.Get("unknown")
+	assert.False(t, ok)
+
+	assert.Equal(t, []string{"email.send", "report.generate"}, registry.Types())
+}
@@ -0,0 +1,61 @@
+package usecase
+
+import (
+	"math/rand/v2"
+	"time"
+
+	"github.com/Egorpalan/workmate-test/config"
+)
+
+// RetryPolicy определяет число попыток выполнения задачи и задержки между ними
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Jitter      float64
+}
+
+// NewRetryPolicy создает политику повторов из конфигурации
+func NewRetryPolicy(cfg config.RetryConfig) RetryPolicy {
+	policy := RetryPolicy{
+		MaxAttempts: cfg.MaxAttempts,
+		BaseDelay:   cfg.BaseDelay,
+		MaxDelay:    cfg.MaxDelay,
+		Jitter:      cfg.Jitter,
+	}
+
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 1
+	}
+	if policy.MaxDelay < policy.BaseDelay {
+		policy.MaxDelay = policy.BaseDelay
+	}
+	if policy.Jitter > 1 {
+		policy.Jitter = 1
+	}
+
+	return policy
+}
+
+// Backoff возвращает задержку перед следующей попыткой после неудачной попытки attempt (начиная с 1):
+// BaseDelay * 2^(attempt-1), ограниченную MaxDelay, со случайным отклонением в пределах Jitter
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	if p.Jitter > 0 {
+		spread := float64(delay) * p.Jitter
+		delay = time.Duration(float64(delay) - spread + rand.Float64()*2*spread)
+	}
+
+	return delay
+}
@@ -0,0 +1,37 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Egorpalan/workmate-test/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRetryPolicy_Backoff тестирует экспоненциальный рост задержки с ограничением сверху
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	assert.Equal(t, time.Second, policy.Backoff(1))
+	assert.Equal(t, 2*time.Second, policy.Backoff(2))
+	assert.Equal(t, 4*time.Second, policy.Backoff(3))
+	assert.Equal(t, 8*time.Second, policy.Backoff(4))
+	assert.Equal(t, 10*time.Second, policy.Backoff(5))
+	assert.Equal(t, 10*time.Second, policy.Backoff(100))
+}
+
+// TestRetryPolicy_Jitter тестирует, что случайное отклонение не выходит за заданные пределы
+func TestRetryPolicy_Jitter(t *testing.T) {
+	policy := NewRetryPolicy(config.RetryConfig{
+		MaxAttempts: 5,
+		BaseDelay:   10 * time.Second,
+		MaxDelay:    time.Minute,
+		Jitter:      0.5,
+	})
+
+	for i := 0; i < 100; i++ {
+		delay := policy.Backoff(1)
+		assert.GreaterOrEqual(t, delay, 5*time.Second)
+		assert.LessOrEqual(t, delay, 15*time.Second)
+	}
+}
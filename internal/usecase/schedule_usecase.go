@@ -0,0 +1,237 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Egorpalan/workmate-test/internal/entity"
+	"github.com/Egorpalan/workmate-test/internal/repository"
+	"github.com/Egorpalan/workmate-test/pkg/logger"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidSchedule возвращается при некорректных параметрах расписания
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// dueSchedulesBatch ограничивает число расписаний, обрабатываемых за один тик
+const dueSchedulesBatch = 100
+
+type scheduleUseCase struct {
+	scheduleRepo repository.ScheduleRepository
+	tasks        TaskUseCase
+	interval     time.Duration
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewScheduleUseCase создает новый экземпляр scheduleUseCase.
+// Задачи по расписаниям создаются через tasks, проверка расписаний выполняется раз в interval.
+func NewScheduleUseCase(scheduleRepo repository.ScheduleRepository, tasks TaskUseCase, interval time.Duration) *scheduleUseCase {
+	return &scheduleUseCase{
+		scheduleRepo: scheduleRepo,
+		tasks:        tasks,
+		interval:     interval,
+	}
+}
+
+// Start запускает планировщик, создающий задачи по наступившим расписаниям
+func (u *scheduleUseCase) Start(ctx context.Context) {
+	ctx, u.cancel = context.WithCancel(ctx)
+	runPeriodically(ctx, &u.wg, u.interval, u.fireDueSchedules)
+}
+
+// Stop останавливает планировщик и ждет завершения текущего тика
+func (u *scheduleUseCase) Stop(ctx context.Context) error {
+	if u.cancel != nil {
+		u.cancel()
+	}
+	return waitGroup(ctx, &u.wg)
+}
+
+// CreateSchedule создает новое расписание и вычисляет время его первого срабатывания
+func (u *scheduleUseCase) CreateSchedule(ctx context.Context, spec entity.ScheduleSpec) (*entity.Schedule, error) {
+	schedule := &entity.Schedule{}
+	if err := u.applySpec(schedule, spec, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if err := u.scheduleRepo.Create(ctx, schedule); err != nil {
+		logger.Error("Failed to create schedule", zap.Error(err))
+		return nil, fmt.Errorf("failed to create schedule: %w", err)
+	}
+
+	return schedule, nil
+}
+
+// GetSchedule возвращает расписание по его ID
+func (u *scheduleUseCase) GetSchedule(ctx context.Context, id string) (*entity.Schedule, error) {
+	schedule, err := u.scheduleRepo.GetByID(ctx, id)
+	if errors.Is(err, entity.ErrScheduleNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		logger.Error("Failed to get schedule by ID", zap.String("id", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to get schedule by id: %w", err)
+	}
+
+	return schedule, nil
+}
+
+// ListSchedules возвращает список расписаний с пагинацией
+func (u *scheduleUseCase) ListSchedules(ctx context.Context, limit, offset int) ([]*entity.Schedule, error) {
+	schedules, err := u.scheduleRepo.List(ctx, limit, offset)
+	if err != nil {
+		logger.Error("Failed to list schedules", zap.Error(err))
+		return nil, fmt.Errorf("failed to list schedules: %w", err)
+	}
+
+	return schedules, nil
+}
+
+// UpdateSchedule полностью заменяет параметры расписания и пересчитывает время следующего срабатывания
+func (u *scheduleUseCase) UpdateSchedule(ctx context.Context, id string, spec entity.ScheduleSpec) (*entity.Schedule, error) {
+	schedule, err := u.GetSchedule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.applySpec(schedule, spec, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if err := u.scheduleRepo.Update(ctx, schedule); err != nil {
+		if errors.Is(err, entity.ErrScheduleNotFound) {
+			return nil, err
+		}
+		logger.Error("Failed to update schedule", zap.String("id", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to update schedule: %w", err)
+	}
+
+	return schedule, nil
+}
+
+// DeleteSchedule удаляет расписание
+func (u *scheduleUseCase) DeleteSchedule(ctx context.Context, id string) error {
+	err := u.scheduleRepo.Delete(ctx, id)
+	if errors.Is(err, entity.ErrScheduleNotFound) {
+		return err
+	}
+	if err != nil {
+		logger.Error("Failed to delete schedule", zap.String("id", id), zap.Error(err))
+		return fmt.Errorf("failed to delete schedule: %w", err)
+	}
+
+	return nil
+}
+
+// applySpec проверяет параметры и переносит их в расписание, вычисляя next_run_at относительно now
+func (u *scheduleUseCase) applySpec(schedule *entity.Schedule, spec entity.ScheduleSpec, now time.Time) error {
+	if !slices.Contains(u.tasks.ListTaskTypes(), spec.TaskType) {
+		return fmt.Errorf("%w: %q", ErrUnknownTaskType, spec.TaskType)
+	}
+
+	timezone := spec.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+
+	cronSchedule, location, err := parseSchedule(spec.CronExpression, timezone)
+	if err != nil {
+		return err
+	}
+
+	enabled := true
+	if spec.Enabled != nil {
+		enabled = *spec.Enabled
+	}
+
+	schedule.Name = spec.Name
+	schedule.CronExpression = spec.CronExpression
+	schedule.TaskType = spec.TaskType
+	schedule.Payload = spec.Payload
+	schedule.Timezone = timezone
+	schedule.Enabled = enabled
+	schedule.NextRunAt = cronSchedule.Next(now.In(location))
+
+	return nil
+}
+
+// fireDueSchedules создает задачи по всем наступившим расписаниям
+func (u *scheduleUseCase) fireDueSchedules(ctx context.Context) {
+	now := time.Now()
+
+	schedules, err := u.scheduleRepo.ListDue(ctx, now, dueSchedulesBatch)
+	if err != nil {
+		logger.Error("Failed to list due schedules", zap.Error(err))
+		return
+	}
+
+	for _, schedule := range schedules {
+		if ctx.Err() != nil {
+			return
+		}
+		u.fireSchedule(ctx, schedule, now)
+	}
+}
+
+// fireSchedule материализует одно срабатывание расписания в задачу через CreateTask, поэтому к ней применяются
+// те же проверки, что и к задачам из API, включая лимит очереди. Ключ идемпотентности срабатывания не дает
+// создать вторую задачу реплике, обработавшей его одновременно, и повтору после сбоя: расписание сдвигается
+// только после создания задачи, а если задача не создана, срабатывание повторяется на следующем тике.
+// Пропущенные срабатывания не догоняются: следующий запуск вычисляется от текущего момента.
+func (u *scheduleUseCase) fireSchedule(ctx context.Context, schedule *entity.Schedule, now time.Time) {
+	cronSchedule, location, err := parseSchedule(schedule.CronExpression, schedule.Timezone)
+	if err != nil {
+		logger.Error("Invalid stored schedule", zap.String("schedule_id", schedule.ID), zap.Error(err))
+		return
+	}
+
+	scheduleID := schedule.ID
+	task, created, err := u.tasks.CreateTask(ctx, entity.TaskSpec{
+		Type:           schedule.TaskType,
+		Payload:        schedule.Payload,
+		ScheduleID:     &scheduleID,
+		IdempotencyKey: entity.ScheduleIdempotencyKey(schedule.ID, schedule.NextRunAt),
+	})
+	if errors.Is(err, ErrUnknownTaskType) {
+		// Обработчик может быть зарегистрирован на другой реплике — оставляем расписание ей
+		logger.Warn("No handler for scheduled task type on this replica",
+			zap.String("schedule_id", schedule.ID), zap.String("type", schedule.TaskType))
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to create scheduled task", zap.String("schedule_id", schedule.ID), zap.Error(err))
+		return
+	}
+
+	fired, err := u.scheduleRepo.Fire(ctx, schedule, cronSchedule.Next(now.In(location)))
+	if err != nil {
+		logger.Error("Failed to fire schedule", zap.String("schedule_id", schedule.ID), zap.Error(err))
+		return
+	}
+
+	if fired && created {
+		logger.Info("Schedule fired", zap.String("schedule_id", schedule.ID), zap.String("task_id", task.ID))
+	}
+}
+
+// parseSchedule разбирает стандартное cron-выражение из пяти полей (или дескриптор вида @hourly) и часовой пояс
+func parseSchedule(expression, timezone string) (cron.Schedule, *time.Location, error) {
+	cronSchedule, err := cron.ParseStandard(expression)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: cron_expression: %v", ErrInvalidSchedule, err)
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: timezone: %v", ErrInvalidSchedule, err)
+	}
+
+	return cronSchedule, location, nil
+}
@@ -0,0 +1,225 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Egorpalan/workmate-test/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockScheduleRepository struct {
+	mock.Mock
+}
+
+func (m *MockScheduleRepository) Create(ctx context.Context, schedule *entity.Schedule) error {
+	args := m.Called(ctx, schedule)
+	schedule.ID = "schedule-id"
+	return args.Error(0)
+}
+
+func (m *MockScheduleRepository) GetByID(ctx context.Context, id string) (*entity.Schedule, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Schedule), args.Error(1)
+}
+
+func (m *MockScheduleRepository) Update(ctx context.Context, schedule *entity.Schedule) error {
+	args := m.Called(ctx, schedule)
+	return args.Error(0)
+}
+
+func (m *MockScheduleRepository) Delete(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockScheduleRepository) List(ctx context.Context, limit, offset int) ([]*entity.Schedule, error) {
+	args := m.Called(ctx, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*entity.Schedule), args.Error(1)
+}
+
+func (m *MockScheduleRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]*entity.Schedule, error) {
+	args := m.Called(ctx, now, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*entity.Schedule), args.Error(1)
+}
+
+func (m *MockScheduleRepository) Fire(ctx context.Context, schedule *entity.Schedule, nextRunAt time.Time) (bool, error) {
+	args := m.Called(ctx, schedule, nextRunAt)
+	return args.Bool(0), args.Error(1)
+}
+
+func newTestScheduleUseCase(repo *MockScheduleRepository, taskRepo *MockTaskRepository) *scheduleUseCase {
+	return NewScheduleUseCase(repo, newTestNoopTaskUseCase(taskRepo), time.Hour)
+}
+
+// TestCreateSchedule проверяет вычисление первого срабатывания с учетом часового пояса
+func TestCreateSchedule(t *testing.T) {
+	mockRepo := new(MockScheduleRepository)
+	mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*entity.Schedule")).Return(nil)
+
+	useCase := newTestScheduleUseCase(mockRepo, new(MockTaskRepository))
+
+	schedule, err := useCase.CreateSchedule(context.Background(), entity.ScheduleSpec{
+		Name:           "nightly",
+		CronExpression: "0 3 * * *",
+		TaskType:       "test",
+		Timezone:       "Europe/Moscow",
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "schedule-id", schedule.ID)
+	assert.True(t, schedule.Enabled)
+	assert.True(t, schedule.NextRunAt.After(time.Now()))
+	assert.Equal(t, 3, schedule.NextRunAt.In(mustLoadLocation(t, "Europe/Moscow")).Hour())
+	mockRepo.AssertExpectations(t)
+}
+
+// TestCreateSchedule_Invalid проверяет отклонение некорректных cron-выражений, часовых поясов и типов задач
+func TestCreateSchedule_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    entity.ScheduleSpec
+		wantErr error
+	}{
+		{"bad cron", entity.ScheduleSpec{CronExpression: "every minute", TaskType: "test"}, ErrInvalidSchedule},
+		{"bad timezone", entity.ScheduleSpec{CronExpression: "* * * * *", TaskType: "test", Timezone: "Mars/Olympus"}, ErrInvalidSchedule},
+		{"unknown type", entity.ScheduleSpec{CronExpression: "* * * * *", TaskType: "unknown"}, ErrUnknownTaskType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockScheduleRepository)
+			useCase := newTestScheduleUseCase(mockRepo, new(MockTaskRepository))
+
+			_, err := useCase.CreateSchedule(context.Background(), tt.spec)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+// TestFireDueSchedules проверяет создание задачи по наступившему расписанию через CreateTask
+// с ключом идемпотентности срабатывания и сдвиг следующего запуска только после создания задачи
+func TestFireDueSchedules(t *testing.T) {
+	newSchedule := func() *entity.Schedule {
+		return &entity.Schedule{
+			ID:             "schedule-id",
+			CronExpression: "*/5 * * * *",
+			TaskType:       "test",
+			Payload:        json.RawMessage(`{"a":1}`),
+			Timezone:       "UTC",
+			Enabled:        true,
+			NextRunAt:      time.Unix(1700000000, 0),
+		}
+	}
+	isScheduledTask := mock.MatchedBy(func(task *entity.Task) bool {
+		return task.Type == "test" && task.Status == entity.TaskStatusPending &&
+			task.ScheduleID != nil && *task.ScheduleID == "schedule-id" && string(task.Payload) == `{"a":1}`
+	})
+
+	t.Run("fired", func(t *testing.T) {
+		mockRepo := new(MockScheduleRepository)
+		mockTaskRepo := new(MockTaskRepository)
+		schedule := newSchedule()
+
+		mockRepo.On("ListDue", mock.Anything, mock.AnythingOfType("time.Time"), dueSchedulesBatch).
+			Return([]*entity.Schedule{schedule}, nil)
+		mockTaskRepo.On("HasIdempotencyKey", mock.Anything, "schedule:schedule-id:1700000000", time.Hour).Return(false, nil)
+		mockTaskRepo.On("CountByStatus", mock.Anything, entity.TaskStatusPending).Return(0, nil)
+		mockTaskRepo.On("CreateIdempotent", mock.Anything, isScheduledTask, "schedule:schedule-id:1700000000",
+			mock.Anything, time.Hour).Return(&entity.IdempotencyKey{}, true, nil)
+		mockRepo.On("Fire", mock.Anything, schedule, mock.MatchedBy(func(next time.Time) bool {
+			return next.After(time.Now()) && next.Minute()%5 == 0
+		})).Return(true, nil)
+
+		useCase := newTestScheduleUseCase(mockRepo, mockTaskRepo)
+		useCase.fireDueSchedules(context.Background())
+
+		mockRepo.AssertExpectations(t)
+		mockTaskRepo.AssertExpectations(t)
+	})
+
+	t.Run("queue full", func(t *testing.T) {
+		mockRepo := new(MockScheduleRepository)
+		mockTaskRepo := new(MockTaskRepository)
+
+		mockRepo.On("ListDue", mock.Anything, mock.AnythingOfType("time.Time"), dueSchedulesBatch).
+			Return([]*entity.Schedule{newSchedule()}, nil)
+		mockTaskRepo.On("HasIdempotencyKey", mock.Anything, "schedule:schedule-id:1700000000", time.Hour).Return(false, nil)
+		mockTaskRepo.On("CountByStatus", mock.Anything, entity.TaskStatusPending).Return(10, nil)
+
+		useCase := newTestScheduleUseCase(mockRepo, mockTaskRepo)
+		useCase.fireDueSchedules(context.Background())
+
+		mockTaskRepo.AssertNotCalled(t, "CreateIdempotent", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		mockRepo.AssertNotCalled(t, "Fire", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+// TestFireDueSchedules_TwoReplicas проверяет, что две реплики, одновременно обрабатывающие одно срабатывание,
+// создают одну задачу по общему ключу идемпотентности, а сдвигает расписание только одна из них
+func TestFireDueSchedules_TwoReplicas(t *testing.T) {
+	mockRepo := new(MockScheduleRepository)
+	mockTaskRepo := new(MockTaskRepository)
+	runAt := time.Unix(1700000000, 0)
+	key := entity.ScheduleIdempotencyKey("schedule-id", runAt)
+
+	for range 2 {
+		mockRepo.On("ListDue", mock.Anything, mock.AnythingOfType("time.Time"), dueSchedulesBatch).
+			Return([]*entity.Schedule{{
+				ID:             "schedule-id",
+				CronExpression: "*/5 * * * *",
+				TaskType:       "test",
+				Timezone:       "UTC",
+				Enabled:        true,
+				NextRunAt:      runAt,
+			}}, nil).Once()
+	}
+	// Обе реплики проверили ключ до того, как одна из них создала задачу
+	mockTaskRepo.On("HasIdempotencyKey", mock.Anything, key, time.Hour).Return(false, nil)
+	mockTaskRepo.On("CountByStatus", mock.Anything, entity.TaskStatusPending).Return(0, nil)
+	mockTaskRepo.On("CreateIdempotent", mock.Anything, mock.AnythingOfType("*entity.Task"), key,
+		mock.Anything, time.Hour).Return(&entity.IdempotencyKey{}, true, nil).Once()
+	stored := &entity.IdempotencyKey{Key: key, TaskID: "mock-id"}
+	mockTaskRepo.On("CreateIdempotent", mock.Anything, mock.AnythingOfType("*entity.Task"), key,
+		mock.Anything, time.Hour).Run(func(args mock.Arguments) {
+		stored.RequestHash = args.String(3)
+	}).Return(stored, false, nil).Once()
+	mockTaskRepo.On("GetByID", mock.Anything, "mock-id").
+		Return(&entity.Task{ID: "mock-id", Type: "test", Status: entity.TaskStatusPending}, nil)
+	mockRepo.On("Fire", mock.Anything, mock.AnythingOfType("*entity.Schedule"), mock.AnythingOfType("time.Time")).
+		Return(true, nil).Once()
+	mockRepo.On("Fire", mock.Anything, mock.AnythingOfType("*entity.Schedule"), mock.AnythingOfType("time.Time")).
+		Return(false, nil).Once()
+
+	first := newTestScheduleUseCase(mockRepo, mockTaskRepo)
+	second := newTestScheduleUseCase(mockRepo, mockTaskRepo)
+	first.fireDueSchedules(context.Background())
+	second.fireDueSchedules(context.Background())
+
+	mockRepo.AssertExpectations(t)
+	mockTaskRepo.AssertExpectations(t)
+	mockTaskRepo.AssertNumberOfCalls(t, "CreateIdempotent", 2)
+}
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("failed to load location %s: %v", name, err)
+	}
+	return location
+}
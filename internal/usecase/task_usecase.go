@@ -1,53 +1,508 @@
 package usecase
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/Egorpalan/workmate-test/config"
 	"github.com/Egorpalan/workmate-test/internal/entity"
 	"github.com/Egorpalan/workmate-test/internal/repository"
 	"github.com/Egorpalan/workmate-test/pkg/logger"
 	"go.uber.org/zap"
 )
 
-// LongRunningTask представляет функцию, выполняющую длительную задачу
-type LongRunningTask func(ctx context.Context) (json.RawMessage, error)
+var (
+	// ErrQueueFull возвращается, когда в очереди достигнут лимит ожидающих задач
+	ErrQueueFull = errors.New("task queue is full")
+	// ErrTaskCancelled используется как причина отмены контекста задачи по запросу клиента
+	ErrTaskCancelled = errors.New("task cancelled by request")
+	// ErrTaskNotCancellable возвращается при попытке отменить уже завершенную задачу
+	ErrTaskNotCancellable = errors.New("task cannot be cancelled in its current status")
+	// ErrTaskTimeout используется как причина отмены контекста задачи, превысившей время выполнения
+	ErrTaskTimeout = errors.New("task execution timed out")
+	// ErrInvalidTaskSpec возвращается при некорректных параметрах создания задачи
+	ErrInvalidTaskSpec = errors.New("invalid task spec")
+	// ErrTaskNotRetryable возвращается при попытке повторить задачу не в статусе failed или dead
+	ErrTaskNotRetryable = errors.New("only failed or dead tasks can be retried")
+	// ErrUnknownQueue возвращается при создании задачи в неизвестной очереди
+	ErrUnknownQueue = errors.New("unknown queue")
+	// ErrIdempotencyKeyReused возвращается, если ключ идемпотентности уже использован с другими параметрами запроса
+	ErrIdempotencyKeyReused = errors.New("idempotency key was already used with a different request")
+)
+
+// maxSaveResultAttempts ограничивает число попыток сохранить результат задачи при конфликте версий
+const maxSaveResultAttempts = 3
+
+// LongRunningTask представляет функцию, выполняющую длительную задачу с входными данными payload
+type LongRunningTask func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error)
 
 type taskUseCase struct {
-	taskRepo    repository.TaskRepository
-	processTask LongRunningTask
+	taskRepo repository.TaskRepository
+	eventBus repository.TaskEventBus
+	events   *EventBroker
+	webhooks *webhookUseCase
+	handlers *TaskHandlerRegistry
+	pools    map[string]*WorkerPool
+	cfg      config.WorkerConfig
+	retry    RetryPolicy
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	runningMu sync.Mutex
+	running   map[string]context.CancelCauseFunc
 }
 
-// NewTaskUseCase создает новый экземпляр taskUseCase
-func NewTaskUseCase(taskRepo repository.TaskRepository, processTask LongRunningTask) *taskUseCase {
+// NewTaskUseCase создает новый экземпляр taskUseCase с отдельным пулом воркеров для каждой очереди из cfg.Queues.
+// cfg.QueueSize ограничивает число ожидающих задач; 0 означает отсутствие лимита.
+// События задач рассылаются через eventBus; если он nil, события доходят только до подписчиков этого процесса.
+// Через webhooks проверяется callback_url задач; доставки вебхуков сохраняет taskRepo вместе с завершением задачи.
+func NewTaskUseCase(
+	taskRepo repository.TaskRepository,
+	eventBus repository.TaskEventBus,
+	webhooks *webhookUseCase,
+	handlers *TaskHandlerRegistry,
+	cfg config.WorkerConfig,
+	retry RetryPolicy,
+) *taskUseCase {
+	pools := make(map[string]*WorkerPool, len(cfg.Queues))
+	for _, queue := range cfg.Queues {
+		pools[queue.Name] = NewWorkerPool(queue.Workers, cfg.PollInterval)
+	}
+
 	return &taskUseCase{
-		taskRepo:    taskRepo,
-		processTask: processTask,
+		taskRepo: taskRepo,
+		eventBus: eventBus,
+		events:   NewEventBroker(),
+		webhooks: webhooks,
+		handlers: handlers,
+		pools:    pools,
+		cfg:      cfg,
+		retry:    retry,
+		running:  make(map[string]context.CancelCauseFunc),
 	}
 }
 
-// CreateTask создает новую задачу и запускает ее асинхронное выполнение
-func (u *taskUseCase) CreateTask(ctx context.Context) (*entity.Task, error) {
+// Start запускает воркеры, забирающие задачи из очереди в базе данных, и фоновые процессы
+func (u *taskUseCase) Start(ctx context.Context) {
+	ctx, u.cancel = context.WithCancel(ctx)
+
+	runPeriodically(ctx, &u.wg, u.cfg.ReapInterval, u.reapExpiredLeases)
+	runPeriodically(ctx, &u.wg, u.cfg.SchedulerInterval, u.promoteScheduledTasks)
+	runPeriodically(ctx, &u.wg, u.cfg.ReapInterval, u.purgeIdempotencyKeys)
+	if u.eventBus != nil {
+		u.wg.Add(1)
+		go func() {
+			defer u.wg.Done()
+			if err := u.eventBus.Listen(ctx, u.events.Publish); err != nil {
+				logger.Error("Task event listener stopped", zap.Error(err))
+			}
+		}()
+	}
+	for _, queue := range u.cfg.Queues {
+		u.pools[queue.Name].Start(ctx, u.queueWorker(queue))
+	}
+}
+
+// Stop останавливает воркеры и фоновые процессы и ждет завершения выполняемых задач
+func (u *taskUseCase) Stop(ctx context.Context) error {
+	if u.cancel != nil {
+		u.cancel()
+	}
+
+	var errs []error
+	for _, pool := range u.pools {
+		errs = append(errs, pool.Stop(ctx))
+	}
+	errs = append(errs, waitGroup(ctx, &u.wg))
+
+	return errors.Join(errs...)
+}
+
+// CreateTask создает новую задачу указанного типа и ставит ее в очередь на выполнение.
+// Задача с run_at или delay_seconds в будущем создается в статусе scheduled и попадет в очередь в назначенное время.
+// Если задан spec.IdempotencyKey и ключ уже использован в пределах cfg.IdempotencyWindow, новая задача
+// не создается: возвращается ранее созданная задача и false, а для другого запроса с тем же ключом — ErrIdempotencyKeyReused.
+// Так же возвращается незавершенная задача того же типа с тем же ключом уникальности.
+func (u *taskUseCase) CreateTask(ctx context.Context, spec entity.TaskSpec) (*entity.Task, bool, error) {
+	if err := u.validateSpec(spec); err != nil {
+		return nil, false, err
+	}
+
+	task := u.newTask(spec, time.Now())
+
+	inserts, err := u.insertsNewPending(ctx, task, spec)
+	if err != nil {
+		return nil, false, err
+	}
+	if inserts {
+		if err := u.checkQueueCapacity(ctx, 1); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if spec.IdempotencyKey != "" {
+		existing, created, err := u.createIdempotent(ctx, task, spec)
+		if err != nil || !created {
+			return existing, false, err
+		}
+	} else {
+		created, err := u.taskRepo.Create(ctx, task)
+		if errors.Is(err, entity.ErrDependencyNotFound) {
+			return nil, false, fmt.Errorf("%w: %w", ErrInvalidTaskSpec, err)
+		}
+		if err != nil {
+			logger.Error("Failed to create task", zap.Error(err))
+			return nil, false, fmt.Errorf("failed to create task: %w", err)
+		}
+		if !created {
+			return task, false, nil
+		}
+	}
+
+	u.publish(ctx, task)
+	if task.Status == entity.TaskStatusPending {
+		u.notify(task.Queue)
+	}
+
+	return task, true, nil
+}
+
+// CreateTasks создает пачку задач в одной транзакции и возвращает ID пачки и ID задач в порядке specs.
+// Если хотя бы одна задача некорректна, не создается ни одна. Для задач, совпавших по ключу уникальности
+// с незавершенными, возвращаются ID существующих задач.
+func (u *taskUseCase) CreateTasks(ctx context.Context, specs []entity.TaskSpec) (*entity.TaskBatch, error) {
+	tasks, batchID, err := u.createMany(ctx, specs, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := &entity.TaskBatch{ID: batchID, TaskIDs: make([]string, 0, len(tasks))}
+	for _, task := range tasks {
+		batch.TaskIDs = append(batch.TaskIDs, task.ID)
+	}
+
+	return batch, nil
+}
+
+// createMany создает пачку задач для CreateTasks и SpawnChildren; parentID задает родительскую задачу, если она есть
+func (u *taskUseCase) createMany(
+	ctx context.Context,
+	specs []entity.TaskSpec,
+	parentID *string,
+) ([]*entity.Task, string, error) {
+	if len(specs) == 0 || len(specs) > entity.MaxTaskBatchSize {
+		return nil, "", fmt.Errorf("%w: batch must contain from 1 to %d tasks", ErrInvalidTaskSpec, entity.MaxTaskBatchSize)
+	}
+
+	now := time.Now()
+	tasks := make([]*entity.Task, 0, len(specs))
+	pending := 0
+	for i, spec := range specs {
+		if err := u.validateSpec(spec); err != nil {
+			return nil, "", fmt.Errorf("task %d: %w", i, err)
+		}
+
+		task := u.newTask(spec, now)
+		task.ParentID = parentID
+		if task.Status == entity.TaskStatusPending {
+			pending++
+		}
+		tasks = append(tasks, task)
+	}
+
+	if pending > 0 {
+		if err := u.checkQueueCapacity(ctx, pending); err != nil {
+			return nil, "", err
+		}
+	}
+
+	batchID, err := u.taskRepo.CreateMany(ctx, tasks)
+	if errors.Is(err, entity.ErrDependencyNotFound) {
+		return nil, "", fmt.Errorf("%w: %w", ErrInvalidTaskSpec, err)
+	}
+	if err != nil {
+		logger.Error("Failed to create tasks", zap.Int("count", len(tasks)), zap.Error(err))
+		return nil, "", fmt.Errorf("failed to create tasks: %w", err)
+	}
+
+	u.publishCreated(ctx, tasks)
+
+	return tasks, batchID, nil
+}
+
+// createTasks собирает задачи по проверенным specs, сохраняет их через insert и рассылает события о них.
+// В лимите очереди учитываются только задачи без зависимостей: остальные сохраняются в статусе blocked.
+// Через него workflowUseCase создает задачи шагов.
+func (u *taskUseCase) createTasks(
+	ctx context.Context,
+	specs []entity.TaskSpec,
+	insert func(tasks []*entity.Task) error,
+) ([]*entity.Task, error) {
+	now := time.Now()
+	tasks := make([]*entity.Task, 0, len(specs))
+	pending := 0
+	for _, spec := range specs {
+		task := u.newTask(spec, now)
+		if task.Status == entity.TaskStatusPending && len(task.DependsOn) == 0 {
+			pending++
+		}
+		tasks = append(tasks, task)
+	}
+
+	if pending > 0 {
+		if err := u.checkQueueCapacity(ctx, pending); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := insert(tasks); err != nil {
+		return nil, err
+	}
+
+	u.publishCreated(ctx, tasks)
+
+	return tasks, nil
+}
+
+// publishCreated рассылает события о созданных задачах и будит воркеры очередей, в которые попали ожидающие задачи
+func (u *taskUseCase) publishCreated(ctx context.Context, tasks []*entity.Task) {
+	queues := make(map[string]struct{})
+	for _, task := range tasks {
+		u.publish(ctx, task)
+		if task.Status == entity.TaskStatusPending {
+			queues[task.Queue] = struct{}{}
+		}
+	}
+	for queue := range queues {
+		u.notify(queue)
+	}
+}
+
+// createIdempotent сохраняет задачу вместе с ключом идемпотентности spec.IdempotencyKey.
+// Если ключ уже использован, возвращает созданную по нему задачу и false.
+func (u *taskUseCase) createIdempotent(
+	ctx context.Context,
+	task *entity.Task,
+	spec entity.TaskSpec,
+) (*entity.Task, bool, error) {
+	requestHash, err := specHash(spec)
+	if err != nil {
+		return nil, false, err
+	}
+
+	stored, created, err := u.taskRepo.CreateIdempotent(ctx, task, spec.IdempotencyKey, requestHash, u.cfg.IdempotencyWindow)
+	if errors.Is(err, entity.ErrDependencyNotFound) {
+		return nil, false, fmt.Errorf("%w: %w", ErrInvalidTaskSpec, err)
+	}
+	if err != nil {
+		logger.Error("Failed to create task", zap.String("idempotency_key", spec.IdempotencyKey), zap.Error(err))
+		return nil, false, fmt.Errorf("failed to create task: %w", err)
+	}
+	if created {
+		return task, true, nil
+	}
+
+	if stored.RequestHash != requestHash {
+		return nil, false, ErrIdempotencyKeyReused
+	}
+
+	existing, err := u.GetTaskByID(ctx, stored.TaskID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return existing, false, nil
+}
+
+// uniqueIDs возвращает ids без повторов в исходном порядке
+func uniqueIDs(ids []string) []string {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]struct{}, len(ids))
+	result := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		result = append(result, id)
+	}
+
+	return result
+}
+
+// uniqueKey возвращает ключ уникальности задачи: явно заданный unique_key
+// или, если запрошена уникальность без ключа, хэш типа и payload
+func uniqueKey(spec entity.TaskSpec) string {
+	if spec.UniqueKey != "" || !spec.Unique {
+		return spec.UniqueKey
+	}
+
+	var payload bytes.Buffer
+	if err := json.Compact(&payload, spec.Payload); err != nil {
+		payload.Write(spec.Payload)
+	}
+
+	sum := sha256.Sum256(append([]byte(spec.Type+"\x00"), payload.Bytes()...))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// specHash возвращает отпечаток параметров создания задачи для сравнения повторов с одним ключом идемпотентности
+func specHash(spec entity.TaskSpec) (string, error) {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal task spec: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// validateSpec проверяет параметры создания задачи
+func (u *taskUseCase) validateSpec(spec entity.TaskSpec) error {
+	if _, ok := u.handlers.Get(spec.Type); !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownTaskType, spec.Type)
+	}
+	if _, ok := u.pools[spec.Queue]; spec.Queue != "" && !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownQueue, spec.Queue)
+	}
+	if spec.TimeoutSeconds < 0 || spec.TimeoutSeconds > entity.MaxTaskTimeoutSeconds {
+		return fmt.Errorf("%w: timeout_seconds must be between 0 and %d",
+			ErrInvalidTaskSpec, entity.MaxTaskTimeoutSeconds)
+	}
+	if spec.Priority < entity.MinTaskPriority || spec.Priority > entity.MaxTaskPriority {
+		return fmt.Errorf("%w: priority must be between %d and %d",
+			ErrInvalidTaskSpec, entity.MinTaskPriority, entity.MaxTaskPriority)
+	}
+	if spec.DelaySeconds < 0 {
+		return fmt.Errorf("%w: delay_seconds must not be negative", ErrInvalidTaskSpec)
+	}
+	if spec.RunAt != nil && spec.DelaySeconds > 0 {
+		return fmt.Errorf("%w: run_at and delay_seconds are mutually exclusive", ErrInvalidTaskSpec)
+	}
+	if len(spec.DependsOn) > entity.MaxTaskDependencies {
+		return fmt.Errorf("%w: task must not depend on more than %d tasks", ErrInvalidTaskSpec, entity.MaxTaskDependencies)
+	}
+	for _, id := range spec.DependsOn {
+		if id == "" {
+			return fmt.Errorf("%w: depends_on must not contain empty IDs", ErrInvalidTaskSpec)
+		}
+	}
+	if len(spec.UniqueKey) > entity.MaxUniqueKeyLength {
+		return fmt.Errorf("%w: unique_key must not be longer than %d characters", ErrInvalidTaskSpec, entity.MaxUniqueKeyLength)
+	}
+	if len(spec.IdempotencyKey) > entity.MaxIdempotencyKeyLength {
+		return fmt.Errorf("%w: idempotency key must not be longer than %d characters",
+			ErrInvalidTaskSpec, entity.MaxIdempotencyKeyLength)
+	}
+	if strings.HasPrefix(spec.IdempotencyKey, entity.ScheduleIdempotencyKeyPrefix) && spec.ScheduleID == nil {
+		return fmt.Errorf("%w: idempotency key must not start with %q",
+			ErrInvalidTaskSpec, entity.ScheduleIdempotencyKeyPrefix)
+	}
+	if spec.CallbackURL != "" {
+		if err := u.webhooks.validateCallbackURL(spec.CallbackURL); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidTaskSpec, err)
+		}
+	}
+
+	return nil
+}
+
+// newTask собирает задачу по параметрам создания; now используется для вычисления отложенного запуска
+func (u *taskUseCase) newTask(spec entity.TaskSpec, now time.Time) *entity.Task {
+	queue := spec.Queue
+	if queue == "" {
+		queue = config.DefaultQueue
+	}
+
 	task := &entity.Task{
-		Status: entity.TaskStatusPending,
-		Result: json.RawMessage([]byte("{}")), // Пустой JSON
+		Type:           spec.Type,
+		Queue:          queue,
+		Payload:        spec.Payload,
+		TimeoutSeconds: spec.TimeoutSeconds,
+		Priority:       spec.Priority,
+		CallbackURL:    spec.CallbackURL,
+		ScheduleID:     spec.ScheduleID,
+		UniqueKey:      uniqueKey(spec),
+		DependsOn:      uniqueIDs(spec.DependsOn),
+		Status:         entity.TaskStatusPending,
+		Result:         json.RawMessage([]byte("{}")), // Пустой JSON
+		MaxAttempts:    u.retry.MaxAttempts,
 	}
 
-	if err := u.taskRepo.Create(ctx, task); err != nil {
-		logger.Error("Failed to create task", zap.Error(err))
-		return nil, fmt.Errorf("failed to create task: %w", err)
+	runAt := now
+	switch {
+	case spec.RunAt != nil:
+		runAt = *spec.RunAt
+	case spec.DelaySeconds > 0:
+		runAt = now.Add(time.Duration(spec.DelaySeconds) * time.Second)
 	}
 
-	go u.processTaskAsync(task.ID)
+	if runAt.After(now) {
+		task.Status = entity.TaskStatusScheduled
+		task.NextRunAt = runAt
+	}
 
-	return task, nil
+	return task
+}
+
+// GetTaskGraph возвращает граф зависимостей, в который входит задача id
+func (u *taskUseCase) GetTaskGraph(ctx context.Context, id string) (*entity.TaskGraph, error) {
+	graph, err := u.taskRepo.GetGraph(ctx, id)
+	if errors.Is(err, entity.ErrTaskNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		logger.Error("Failed to get task graph", zap.String("id", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to get task graph: %w", err)
+	}
+
+	return graph, nil
+}
+
+// GetTaskChildren возвращает число дочерних задач задачи id в каждом статусе и страницу их списка
+func (u *taskUseCase) GetTaskChildren(ctx context.Context, id string, limit, offset int) (*entity.TaskChildren, error) {
+	if _, err := u.GetTaskByID(ctx, id); err != nil {
+		return nil, err
+	}
+
+	counts, err := u.taskRepo.CountChildren(ctx, id)
+	if err != nil {
+		logger.Error("Failed to count child tasks", zap.String("id", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to count child tasks: %w", err)
+	}
+
+	tasks, err := u.ListTasks(ctx, entity.TaskFilter{ParentID: id, Limit: limit, Offset: offset})
+	if err != nil {
+		return nil, err
+	}
+
+	children := &entity.TaskChildren{Counts: counts, Tasks: tasks}
+	for _, count := range counts {
+		children.Total += count
+	}
+
+	return children, nil
 }
 
 // GetTaskByID возвращает задачу по ее ID
 func (u *taskUseCase) GetTaskByID(ctx context.Context, id string) (*entity.Task, error) {
 	task, err := u.taskRepo.GetByID(ctx, id)
+	if errors.Is(err, entity.ErrTaskNotFound) {
+		return nil, err
+	}
 	if err != nil {
 		logger.Error("Failed to get task by ID", zap.String("id", id), zap.Error(err))
 		return nil, fmt.Errorf("failed to get task by id: %w", err)
@@ -56,9 +511,46 @@ func (u *taskUseCase) GetTaskByID(ctx context.Context, id string) (*entity.Task,
 	return task, nil
 }
 
-// ListTasks возвращает список задач с пагинацией
-func (u *taskUseCase) ListTasks(ctx context.Context, limit, offset int) ([]*entity.Task, error) {
-	tasks, err := u.taskRepo.List(ctx, limit, offset)
+// WaitTask ждет, пока задача перейдет в конечный статус, но не дольше timeout, и возвращает ее текущее состояние.
+// Изменения задачи отслеживаются по событиям, а на случай потерянного события задача периодически перечитывается.
+func (u *taskUseCase) WaitTask(ctx context.Context, id string, timeout time.Duration) (*entity.Task, error) {
+	// Подписываемся до чтения задачи, чтобы не пропустить переход в конечный статус
+	events, unsubscribe := u.events.Subscribe(id)
+	defer unsubscribe()
+
+	task, err := u.GetTaskByID(ctx, id)
+	if err != nil || task.Status.IsTerminal() || timeout <= 0 {
+		return task, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	recheck := time.NewTicker(u.cfg.PollInterval)
+	defer recheck.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return task, nil
+		case <-timer.C:
+			return u.GetTaskByID(ctx, id)
+		case event := <-events:
+			if !event.Status.IsTerminal() {
+				continue
+			}
+		case <-recheck.C:
+		}
+
+		task, err = u.GetTaskByID(ctx, id)
+		if err != nil || task.Status.IsTerminal() {
+			return task, err
+		}
+	}
+}
+
+// ListTasks возвращает список задач с пагинацией и фильтром по статусу
+func (u *taskUseCase) ListTasks(ctx context.Context, filter entity.TaskFilter) ([]*entity.Task, error) {
+	tasks, err := u.taskRepo.List(ctx, filter)
 	if err != nil {
 		logger.Error("Failed to list tasks", zap.Error(err))
 		return nil, fmt.Errorf("failed to list tasks: %w", err)
@@ -67,34 +559,594 @@ func (u *taskUseCase) ListTasks(ctx context.Context, limit, offset int) ([]*enti
 	return tasks, nil
 }
 
-// processTaskAsync выполняет задачу асинхронно
-func (u *taskUseCase) processTaskAsync(taskID string) {
-	ctx := context.Background()
+// ListTaskTypes возвращает список зарегистрированных типов задач
+func (u *taskUseCase) ListTaskTypes() []string {
+	return u.handlers.Types()
+}
 
-	task, err := u.taskRepo.GetByID(ctx, taskID)
+// CancelTask отменяет задачу: ожидающая задача сразу переводится в статус cancelled,
+// а выполняемой выставляется флаг отмены, который воркер любой реплики увидит при продлении аренды.
+// Ненулевой version задает ожидаемую версию задачи, при несовпадении возвращается entity.ErrConflict.
+func (u *taskUseCase) CancelTask(ctx context.Context, id string, version int) (*entity.Task, error) {
+	task, err := u.taskRepo.RequestCancel(ctx, id, version)
+	if errors.Is(err, entity.ErrTaskNotFound) || errors.Is(err, entity.ErrConflict) {
+		return nil, err
+	}
+	if errors.Is(err, entity.ErrTaskFinished) {
+		return nil, ErrTaskNotCancellable
+	}
 	if err != nil {
-		logger.Error("Failed to get task by ID for processing", zap.String("id", taskID), zap.Error(err))
-		return
+		logger.Error("Failed to cancel task", zap.String("id", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to cancel task: %w", err)
 	}
 
-	task.Status = entity.TaskStatusProcessing
-	if err := u.taskRepo.Update(ctx, task); err != nil {
-		logger.Error("Failed to update task status to processing", zap.String("id", taskID), zap.Error(err))
-		return
+	u.finishCancel(ctx, task)
+
+	return task, nil
+}
+
+// RetryTask возвращает задачу в статусе failed или dead в очередь со сброшенным счетчиком попыток.
+// Ненулевой version задает ожидаемую версию задачи, как в CancelTask. Задачу, одна из зависимостей которой
+// не выполнилась, повторить нельзя, пока не повторена сама зависимость: возвращается entity.ErrInvalidTransition.
+func (u *taskUseCase) RetryTask(ctx context.Context, id string, version int) (*entity.Task, error) {
+	if err := u.checkQueueCapacity(ctx, 1); err != nil {
+		return nil, err
+	}
+
+	task, err := u.taskRepo.Requeue(ctx, id, version)
+	if errors.Is(err, entity.ErrTaskNotFound) || errors.Is(err, entity.ErrDuplicateTask) ||
+		errors.Is(err, entity.ErrConflict) || errors.Is(err, entity.ErrInvalidTransition) {
+		return nil, err
+	}
+	if errors.Is(err, entity.ErrUnexpectedStatus) {
+		return nil, ErrTaskNotRetryable
+	}
+	if err != nil {
+		logger.Error("Failed to retry task", zap.String("id", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to retry task: %w", err)
 	}
 
-	result, err := u.processTask(ctx)
+	u.publish(ctx, task)
+	if task.Status == entity.TaskStatusPending {
+		u.notify(task.Queue)
+	}
 
-	task.UpdatedAt = time.Now()
+	return task, nil
+}
+
+// SubscribeTaskEvents подписывается на изменения задачи taskID или всех задач, если taskID пуст.
+// Возвращает канал событий и функцию отписки, которую нужно вызвать по завершении чтения.
+func (u *taskUseCase) SubscribeTaskEvents(taskID string) (<-chan entity.TaskEvent, func()) {
+	return u.events.Subscribe(taskID)
+}
+
+// ListQueues возвращает состояние всех настроенных очередей: число воркеров, лимит, глубину,
+// число выполняемых задач и возраст самой старой ожидающей задачи
+func (u *taskUseCase) ListQueues(ctx context.Context) ([]*entity.QueueStats, error) {
+	stats, err := u.taskRepo.QueueStats(ctx)
 	if err != nil {
-		task.Status = entity.TaskStatusFailed
-		task.Error = err.Error()
-	} else {
+		logger.Error("Failed to get queue stats", zap.Error(err))
+		return nil, fmt.Errorf("failed to get queue stats: %w", err)
+	}
+
+	byName := make(map[string]*entity.QueueStats, len(stats))
+	for _, s := range stats {
+		byName[s.Name] = s
+	}
+
+	queues := make([]*entity.QueueStats, 0, len(u.cfg.Queues))
+	for _, queue := range u.cfg.Queues {
+		s, ok := byName[queue.Name]
+		if !ok {
+			s = &entity.QueueStats{Name: queue.Name}
+		}
+		s.Workers = queue.Workers
+		s.MaxInFlight = queue.MaxInFlight
+		queues = append(queues, s)
+	}
+
+	return queues, nil
+}
+
+// insertsNewPending сообщает, добавит ли CreateTask в очередь новую ожидающую задачу и нужно ли проверять лимит очереди.
+// Повтор запроса с ключом идемпотентности и задача, совпавшая по ключу уникальности с незавершенной,
+// новую строку не добавляют и возвращают существующую задачу даже при полной очереди.
+// Проверка не атомарна с созданием: ключ, освободившийся между ними, может превысить лимит на одну задачу.
+func (u *taskUseCase) insertsNewPending(ctx context.Context, task *entity.Task, spec entity.TaskSpec) (bool, error) {
+	if task.Status != entity.TaskStatusPending || u.cfg.QueueSize <= 0 {
+		return false, nil
+	}
+
+	if spec.IdempotencyKey != "" {
+		used, err := u.taskRepo.HasIdempotencyKey(ctx, spec.IdempotencyKey, u.cfg.IdempotencyWindow)
+		if err != nil {
+			return false, err
+		}
+		if used {
+			return false, nil
+		}
+	}
+	if task.UniqueKey != "" {
+		duplicate, err := u.taskRepo.HasUnfinished(ctx, task.Type, task.UniqueKey)
+		if err != nil {
+			return false, err
+		}
+		if duplicate {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// checkQueueCapacity возвращает ErrQueueFull, если добавление n ожидающих задач превысит лимит очереди
+func (u *taskUseCase) checkQueueCapacity(ctx context.Context, n int) error {
+	if u.cfg.QueueSize <= 0 {
+		return nil
+	}
+
+	pending, err := u.taskRepo.CountByStatus(ctx, entity.TaskStatusPending)
+	if err != nil {
+		logger.Error("Failed to count pending tasks", zap.Error(err))
+		return fmt.Errorf("failed to count pending tasks: %w", err)
+	}
+	if pending+n > u.cfg.QueueSize {
+		return ErrQueueFull
+	}
+
+	return nil
+}
+
+// queueWorker возвращает функцию воркера, обрабатывающего задачи очереди queue
+func (u *taskUseCase) queueWorker(queue config.QueueConfig) WorkFunc {
+	return func(ctx context.Context) bool {
+		return u.processNextTask(ctx, queue)
+	}
+}
+
+// processNextTask забирает из очереди queue следующую задачу и выполняет ее.
+// Возвращает false, если готовых к выполнению задач нет.
+func (u *taskUseCase) processNextTask(ctx context.Context, queue config.QueueConfig) bool {
+	task, err := u.taskRepo.ClaimNext(ctx, entity.ClaimOptions{
+		WorkerID:      u.cfg.ID,
+		Queue:         queue.Name,
+		LeaseDuration: u.cfg.LeaseDuration,
+		TaskTypes:     u.handlers.Types(),
+		PriorityAging: u.cfg.PriorityAging,
+		MaxInFlight:   queue.MaxInFlight,
+	})
+	if errors.Is(err, entity.ErrNoPendingTasks) {
+		return false
+	}
+	if err != nil {
+		logger.Error("Failed to claim next task", zap.Error(err))
+		return false
+	}
+	u.publish(ctx, task)
+
+	u.executeTask(ctx, task)
+	return true
+}
+
+// executeTask выполняет уже захваченную задачу и сохраняет результат.
+// Пока задача выполняется, ее аренда периодически продлевается, а контекст
+// отменяется при запросе отмены, потере аренды или истечении времени выполнения.
+// Неудачная попытка планируется повторно по политике повторов, пока не исчерпан лимит попыток;
+// задача, превысившая время выполнения, сразу переводится в failed с кодом ошибки timeout.
+// Задача, обработчик которой создал дочерние задачи, после успешного выполнения ждет их в статусе blocked.
+func (u *taskUseCase) executeTask(ctx context.Context, task *entity.Task) {
+	startedAt := time.Now()
+	if task.StartedAt != nil {
+		startedAt = *task.StartedAt
+	}
+	from := task.Status
+
+	processTask, ok := u.handlers.Get(task.Type)
+	if !ok {
+		// Воркер забирает только известные ему типы, поэтому сюда попадаем лишь при гонке с изменением реестра
+		processTask = func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownTaskType, task.Type)
+		}
+	}
+
+	taskCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	u.trackRunning(task.ID, cancel)
+	defer u.untrackRunning(task.ID)
+
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		u.keepLease(taskCtx, task.ID, cancel)
+	}()
+
+	runCtx, cancelRun := context.WithTimeoutCause(taskCtx, u.taskTimeout(task), ErrTaskTimeout)
+	runCtx = withProgressReporter(runCtx, func(ctx context.Context, percent int, message string) error {
+		if err := u.reportProgress(ctx, task.ID, percent, message); err != nil {
+			return err
+		}
+
+		event := entity.NewTaskEvent(task)
+		event.Progress = percent
+		event.ProgressMessage = message
+		u.publishEvent(ctx, event)
+		return nil
+	})
+	var spawned atomic.Bool
+	runCtx = withChildSpawner(runCtx, func(ctx context.Context, specs []entity.TaskSpec) ([]*entity.Task, error) {
+		children, _, err := u.createMany(ctx, specs, &task.ID)
+		if err != nil {
+			return nil, err
+		}
+		spawned.Store(true)
+		return children, nil
+	})
+	result, err := processTask(runCtx, task.Payload)
+	cause := context.Cause(runCtx)
+
+	cancelRun()
+	cancel(nil)
+	<-heartbeatDone
+
+	if errors.Is(cause, entity.ErrLeaseLost) {
+		logger.Warn("Task lease lost, discarding result", zap.String("id", task.ID))
+		return
+	}
+
+	now := time.Now()
+	task.UpdatedAt = now
+	task.ErrorCode = ""
+	// Результат успешно завершившегося обработчика сохраняется, даже если контекст уже отменен:
+	// повторный запуск повторил бы побочные эффекты обработчика
+	switch {
+	case err == nil && spawned.Load():
+		task.Status = entity.TaskStatusBlocked
+		task.Result = result
+		task.Error = ""
+	case err == nil:
 		task.Status = entity.TaskStatusCompleted
 		task.Result = result
+		task.Error = ""
+		task.Progress = 100
+	case errors.Is(cause, ErrTaskTimeout):
+		task.Status = entity.TaskStatusFailed
+		task.Error = ErrTaskTimeout.Error()
+		task.ErrorCode = entity.ErrorCodeTimeout
+	case errors.Is(cause, ErrTaskCancelled):
+		task.Status = entity.TaskStatusCancelled
+		task.Error = ErrTaskCancelled.Error()
+	case ctx.Err() != nil:
+		// Воркер останавливается: возвращаем задачу в очередь, чтобы ее подхватила другая реплика
+		task.Status = entity.TaskStatusPending
+		task.Error = "interrupted by worker shutdown"
+		task.NextRunAt = now
+	default:
+		task.Error = err.Error()
+		if task.Attempts < task.MaxAttempts {
+			task.Status = entity.TaskStatusPending
+			task.NextRunAt = now.Add(u.retry.Backoff(task.Attempts))
+		} else {
+			task.Status = entity.TaskStatusDead
+		}
+	}
+
+	attempt := entity.TaskAttempt{
+		Attempt:    task.Attempts,
+		WorkerID:   u.cfg.ID,
+		StartedAt:  startedAt,
+		FinishedAt: now,
+	}
+	if task.Status != entity.TaskStatusCompleted && task.Status != entity.TaskStatusBlocked {
+		attempt.Error = task.Error
+	}
+	task.AttemptHistory = append(task.AttemptHistory, attempt)
+
+	if err := entity.ValidateTransition(from, task.Status); err != nil {
+		logger.Error("Refusing to save task result", zap.String("id", task.ID), zap.Error(err))
+		return
+	}
+
+	// Результат сохраняем даже если контекст воркера уже отменен при остановке
+	err = u.saveResult(context.WithoutCancel(ctx), task, from)
+	if errors.Is(err, entity.ErrInvalidTransition) {
+		// Задачу успели отменить, вернуть в очередь по истечении аренды или захватить заново
+		logger.Warn("Task changed concurrently, discarding result", zap.String("id", task.ID), zap.Error(err))
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to update task with result", zap.String("id", task.ID), zap.Error(err))
+		return
+	}
+	u.publish(ctx, task)
+	u.resolveDependents(ctx, task)
+	switch {
+	case task.Status == entity.TaskStatusBlocked:
+		// Дочерние задачи могли завершиться раньше обработчика
+		u.completeParent(ctx, task.ID)
+	case spawned.Load() && task.Status != entity.TaskStatusCancelled:
+		// Повторная попытка создаст дочерние задачи заново, а результаты этой попытки не должны попасть в ее результат
+		u.detachChildren(ctx, task.ID)
+	}
+}
+
+// saveResult сохраняет результат выполнения задачи, переводя ее из статуса from.
+// Версия задачи меняется и без смены владельца, например при сохранении прогресса или запросе отмены,
+// поэтому при конфликте версий задача перечитывается, и результат сохраняется заново, пока задачу
+// выполняет та же попытка этого воркера. Иначе возвращается entity.ErrInvalidTransition.
+func (u *taskUseCase) saveResult(ctx context.Context, task *entity.Task, from entity.TaskStatus) error {
+	for attempt := 1; ; attempt++ {
+		err := u.taskRepo.Update(ctx, task, from)
+		if !errors.Is(err, entity.ErrConflict) || attempt == maxSaveResultAttempts {
+			return err
+		}
+
+		current, err := u.taskRepo.GetByID(ctx, task.ID)
+		if err != nil {
+			return err
+		}
+		if current.Status != from || current.WorkerID != task.WorkerID || current.Attempts != task.Attempts {
+			return fmt.Errorf("%w: task was taken over by worker %q, attempt %d",
+				entity.ErrInvalidTransition, current.WorkerID, current.Attempts)
+		}
+		task.Version = current.Version
+	}
+}
+
+// reportProgress сохраняет прогресс задачи, опубликованный ее обработчиком через ReportProgress
+func (u *taskUseCase) reportProgress(ctx context.Context, taskID string, percent int, message string) error {
+	err := u.taskRepo.UpdateProgress(ctx, taskID, u.cfg.ID, percent, message)
+	if errors.Is(err, entity.ErrLeaseLost) {
+		return err
+	}
+	if err != nil {
+		logger.Error("Failed to report task progress", zap.String("id", taskID), zap.Error(err))
+		return fmt.Errorf("failed to report task progress: %w", err)
+	}
+
+	return nil
+}
+
+// taskTimeout возвращает время выполнения задачи: собственное, если оно задано, иначе из конфигурации
+func (u *taskUseCase) taskTimeout(task *entity.Task) time.Duration {
+	if task.TimeoutSeconds > 0 {
+		return time.Duration(task.TimeoutSeconds) * time.Second
+	}
+	return u.cfg.TaskTimeout
+}
+
+// keepLease продлевает аренду задачи, пока не отменен контекст.
+// Если аренда потеряна или в БД выставлен флаг отмены, контекст задачи отменяется с соответствующей причиной.
+func (u *taskUseCase) keepLease(ctx context.Context, taskID string, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(u.cfg.LeaseDuration / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cancelRequested, err := u.taskRepo.ExtendLease(ctx, taskID, u.cfg.ID, u.cfg.LeaseDuration)
+			if errors.Is(err, entity.ErrLeaseLost) {
+				cancel(err)
+				return
+			}
+			if err != nil {
+				if ctx.Err() == nil {
+					logger.Error("Failed to extend task lease", zap.String("id", taskID), zap.Error(err))
+				}
+				continue
+			}
+			if cancelRequested {
+				cancel(ErrTaskCancelled)
+				return
+			}
+		}
+	}
+}
+
+// trackRunning запоминает функцию отмены задачи, выполняемой на этой реплике
+func (u *taskUseCase) trackRunning(taskID string, cancel context.CancelCauseFunc) {
+	u.runningMu.Lock()
+	defer u.runningMu.Unlock()
+	u.running[taskID] = cancel
+}
+
+// untrackRunning забывает задачу после завершения ее выполнения
+func (u *taskUseCase) untrackRunning(taskID string) {
+	u.runningMu.Lock()
+	defer u.runningMu.Unlock()
+	delete(u.running, taskID)
+}
+
+// cancelRunning отменяет задачу, если она выполняется на этой реплике
+func (u *taskUseCase) cancelRunning(taskID string) bool {
+	u.runningMu.Lock()
+	defer u.runningMu.Unlock()
+
+	cancel, ok := u.running[taskID]
+	if ok {
+		cancel(ErrTaskCancelled)
+	}
+	return ok
+}
+
+// promoteScheduledTasks ставит в очередь отложенные задачи, время запуска которых наступило
+func (u *taskUseCase) promoteScheduledTasks(ctx context.Context) {
+	promoted, err := u.taskRepo.PromoteDue(ctx)
+	if err != nil {
+		logger.Error("Failed to promote scheduled tasks", zap.Error(err))
+		return
+	}
+
+	if len(promoted) > 0 {
+		logger.Info("Promoted scheduled tasks", zap.Int("count", len(promoted)))
+		for _, task := range promoted {
+			u.publish(ctx, task)
+		}
+		u.notifyAll()
+	}
+}
+
+// reapExpiredLeases возвращает в очередь задачи, воркеры которых перестали продлевать аренду
+func (u *taskUseCase) reapExpiredLeases(ctx context.Context) {
+	released, err := u.taskRepo.ReleaseExpired(ctx)
+	if err != nil {
+		logger.Error("Failed to release expired tasks", zap.Error(err))
+		return
+	}
+
+	if len(released) > 0 {
+		logger.Info("Released tasks with expired leases", zap.Int("count", len(released)))
+		for _, task := range released {
+			u.publish(ctx, task)
+			u.resolveDependents(ctx, task)
+		}
+		u.notifyAll()
+	}
+}
+
+// purgeIdempotencyKeys удаляет ключи идемпотентности, срок действия которых истек
+func (u *taskUseCase) purgeIdempotencyKeys(ctx context.Context) {
+	deleted, err := u.taskRepo.DeleteExpiredIdempotencyKeys(ctx, u.cfg.IdempotencyWindow)
+	if err != nil {
+		logger.Error("Failed to purge idempotency keys", zap.Error(err))
+		return
+	}
+
+	if deleted > 0 {
+		logger.Info("Purged expired idempotency keys", zap.Int64("count", deleted))
+	}
+}
+
+// resolveDependents продвигает граф зависимостей после того, как задача task перешла в конечный статус:
+// после успешного завершения ставит в очередь задачи, дождавшиеся всех зависимостей,
+// а после неудачи или отмены помечает упавшими все задачи, которые ее ждут.
+// Если task — дочерняя задача, проверяет, не пора ли завершить родительскую; отмена task отменяет ее дочерние задачи.
+func (u *taskUseCase) resolveDependents(ctx context.Context, task *entity.Task) {
+	// Граф продвигаем даже если контекст воркера уже отменен при остановке
+	ctx = context.WithoutCancel(ctx)
+
+	if task.ParentID != nil && task.Status.IsTerminal() {
+		u.completeParent(ctx, *task.ParentID)
+	}
+	if task.Status == entity.TaskStatusCancelled {
+		u.cancelChildren(ctx, task.ID)
+	}
+
+	switch task.Status {
+	case entity.TaskStatusCompleted:
+		released, err := u.taskRepo.ReleaseDependents(ctx, task.ID)
+		if err != nil {
+			logger.Error("Failed to release dependent tasks", zap.String("id", task.ID), zap.Error(err))
+			return
+		}
+		for _, dependent := range released {
+			u.publish(ctx, dependent)
+			if dependent.Status == entity.TaskStatusPending {
+				u.notify(dependent.Queue)
+			}
+		}
+	case entity.TaskStatusFailed, entity.TaskStatusDead, entity.TaskStatusCancelled:
+		failed, err := u.taskRepo.FailDependents(ctx, task.ID)
+		if err != nil {
+			logger.Error("Failed to fail dependent tasks", zap.String("id", task.ID), zap.Error(err))
+			return
+		}
+		for _, dependent := range failed {
+			u.publish(ctx, dependent)
+			if dependent.ParentID != nil {
+				u.completeParent(ctx, *dependent.ParentID)
+			}
+		}
+	}
+}
+
+// completeParent завершает задачу parentID, если она ждет дочерние задачи и все они уже завершены.
+// Завершенную или отмененную задачу репозиторий не меняет.
+func (u *taskUseCase) completeParent(ctx context.Context, parentID string) {
+	ctx = context.WithoutCancel(ctx)
+
+	parent, completed, err := u.taskRepo.CompleteParent(ctx, parentID)
+	if err != nil {
+		logger.Error("Failed to complete parent task", zap.String("id", parentID), zap.Error(err))
+		return
+	}
+	if !completed {
+		return
+	}
+
+	u.publish(ctx, parent)
+	u.resolveDependents(ctx, parent)
+}
+
+// cancelChildren отменяет незавершенные дочерние задачи отмененной задачи parentID.
+// Отмена каждой из них продвигает граф так же, как CancelTask, и распространяется на ее дочерние задачи.
+func (u *taskUseCase) cancelChildren(ctx context.Context, parentID string) {
+	children, err := u.taskRepo.CancelChildren(ctx, parentID)
+	if err != nil {
+		logger.Error("Failed to cancel child tasks", zap.String("parent_id", parentID), zap.Error(err))
+		return
+	}
+
+	for _, child := range children {
+		u.finishCancel(ctx, child)
+	}
+}
+
+// detachChildren отвязывает дочерние задачи неудавшейся попытки задачи parentID и отменяет незавершенные из них
+func (u *taskUseCase) detachChildren(ctx context.Context, parentID string) {
+	ctx = context.WithoutCancel(ctx)
+
+	children, err := u.taskRepo.DetachChildren(ctx, parentID)
+	if err != nil {
+		logger.Error("Failed to detach child tasks", zap.String("parent_id", parentID), zap.Error(err))
+		return
+	}
+
+	for _, child := range children {
+		u.finishCancel(ctx, child)
+	}
+}
+
+// finishCancel доводит до конца отмену задачи task, записанную в репозиторий: прерывает ее выполнение
+// на этой реплике, рассылает событие и продвигает граф зависимостей
+func (u *taskUseCase) finishCancel(ctx context.Context, task *entity.Task) {
+	if task.Status == entity.TaskStatusProcessing {
+		u.cancelRunning(task.ID)
+	}
+	u.publish(ctx, task)
+	u.resolveDependents(ctx, task)
+}
+
+// publish рассылает событие с текущим состоянием задачи
+func (u *taskUseCase) publish(ctx context.Context, task *entity.Task) {
+	u.publishEvent(ctx, entity.NewTaskEvent(task))
+}
+
+// publishEvent рассылает событие подписчикам всех реплик через eventBus.
+// Если отправить событие не удалось, оно доставляется хотя бы подписчикам этой реплики.
+func (u *taskUseCase) publishEvent(ctx context.Context, event entity.TaskEvent) {
+	if u.eventBus == nil {
+		u.events.Publish(event)
+		return
+	}
+
+	if err := u.eventBus.Publish(context.WithoutCancel(ctx), event); err != nil {
+		u.events.Publish(event)
 	}
+}
+
+// notify будит воркер очереди queue
+func (u *taskUseCase) notify(queue string) {
+	if pool, ok := u.pools[queue]; ok {
+		pool.Notify()
+	}
+}
 
-	if err := u.taskRepo.Update(ctx, task); err != nil {
-		logger.Error("Failed to update task with result", zap.String("id", taskID), zap.Error(err))
+// notifyAll будит по одному воркеру в каждой очереди
+func (u *taskUseCase) notifyAll() {
+	for _, pool := range u.pools {
+		pool.Notify()
 	}
 }
@@ -4,11 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/Egorpalan/workmate-test/pkg/logger"
 	"os"
 	"testing"
 	"time"
 
+	"github.com/Egorpalan/workmate-test/config"
 	"github.com/Egorpalan/workmate-test/internal/entity"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -24,14 +26,55 @@ type MockTaskRepository struct {
 	mock.Mock
 }
 
-func (m *MockTaskRepository) Create(ctx context.Context, task *entity.Task) error {
+func (m *MockTaskRepository) Create(ctx context.Context, task *entity.Task) (bool, error) {
 	args := m.Called(ctx, task)
 
 	task.ID = "mock-id"
 	task.CreatedAt = time.Now()
 	task.UpdatedAt = time.Now()
 
-	return args.Error(0)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockTaskRepository) CreateMany(ctx context.Context, tasks []*entity.Task) (string, error) {
+	args := m.Called(ctx, tasks)
+	for i, task := range tasks {
+		task.ID = fmt.Sprintf("mock-id-%d", i)
+	}
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockTaskRepository) CreateIdempotent(
+	ctx context.Context,
+	task *entity.Task,
+	key, requestHash string,
+	window time.Duration,
+) (*entity.IdempotencyKey, bool, error) {
+	args := m.Called(ctx, task, key, requestHash, window)
+	if args.Bool(1) {
+		task.ID = "mock-id"
+		task.CreatedAt = time.Now()
+		task.UpdatedAt = time.Now()
+	}
+	if args.Get(0) == nil {
+		return nil, args.Bool(1), args.Error(2)
+	}
+	return args.Get(0).(*entity.IdempotencyKey), args.Bool(1), args.Error(2)
+}
+
+func (m *MockTaskRepository) DeleteExpiredIdempotencyKeys(ctx context.Context, window time.Duration) (int64, error) {
+	args := m.Called(ctx, window)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockTaskRepository) HasIdempotencyKey(ctx context.Context, key string, window time.Duration) (bool, error) {
+	args := m.Called(ctx, key, window)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockTaskRepository) HasUnfinished(ctx context.Context, taskType, uniqueKey string) (bool, error) {
+	args := m.Called(ctx, taskType, uniqueKey)
+	return args.Bool(0), args.Error(1)
 }
 
 func (m *MockTaskRepository) GetByID(ctx context.Context, id string) (*entity.Task, error) {
@@ -42,33 +85,214 @@ func (m *MockTaskRepository) GetByID(ctx context.Context, id string) (*entity.Ta
 	return args.Get(0).(*entity.Task), args.Error(1)
 }
 
-func (m *MockTaskRepository) Update(ctx context.Context, task *entity.Task) error {
-	args := m.Called(ctx, task)
+func (m *MockTaskRepository) Update(ctx context.Context, task *entity.Task, from entity.TaskStatus) error {
+	args := m.Called(ctx, task, from)
+	return args.Error(0)
+}
+
+func (m *MockTaskRepository) List(ctx context.Context, filter entity.TaskFilter) ([]*entity.Task, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*entity.Task), args.Error(1)
+}
+
+func (m *MockTaskRepository) ClaimNext(ctx context.Context, opts entity.ClaimOptions) (*entity.Task, error) {
+	args := m.Called(ctx, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Task), args.Error(1)
+}
+
+func (m *MockTaskRepository) CountByStatus(ctx context.Context, status entity.TaskStatus) (int, error) {
+	args := m.Called(ctx, status)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockTaskRepository) ExtendLease(ctx context.Context, id, workerID string, leaseDuration time.Duration) (bool, error) {
+	args := m.Called(ctx, id, workerID, leaseDuration)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockTaskRepository) UpdateProgress(ctx context.Context, id, workerID string, progress int, message string) error {
+	args := m.Called(ctx, id, workerID, progress, message)
 	return args.Error(0)
 }
 
-func (m *MockTaskRepository) List(ctx context.Context, limit, offset int) ([]*entity.Task, error) {
-	args := m.Called(ctx, limit, offset)
+func (m *MockTaskRepository) RequestCancel(ctx context.Context, id string, version int) (*entity.Task, error) {
+	args := m.Called(ctx, id, version)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Task), args.Error(1)
+}
+
+func (m *MockTaskRepository) PromoteDue(ctx context.Context) ([]*entity.Task, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*entity.Task), args.Error(1)
+}
+
+func (m *MockTaskRepository) ReleaseExpired(ctx context.Context) ([]*entity.Task, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*entity.Task), args.Error(1)
+}
+
+func (m *MockTaskRepository) QueueStats(ctx context.Context) ([]*entity.QueueStats, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*entity.QueueStats), args.Error(1)
+}
+
+func (m *MockTaskRepository) Requeue(ctx context.Context, id string, version int) (*entity.Task, error) {
+	args := m.Called(ctx, id, version)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Task), args.Error(1)
+}
+
+func (m *MockTaskRepository) ReleaseDependents(ctx context.Context, parentID string) ([]*entity.Task, error) {
+	args := m.Called(ctx, parentID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).([]*entity.Task), args.Error(1)
 }
 
+func (m *MockTaskRepository) FailDependents(ctx context.Context, parentID string) ([]*entity.Task, error) {
+	args := m.Called(ctx, parentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*entity.Task), args.Error(1)
+}
+
+func (m *MockTaskRepository) GetGraph(ctx context.Context, id string) (*entity.TaskGraph, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.TaskGraph), args.Error(1)
+}
+
+func (m *MockTaskRepository) CountChildren(ctx context.Context, parentID string) (map[entity.TaskStatus]int, error) {
+	args := m.Called(ctx, parentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[entity.TaskStatus]int), args.Error(1)
+}
+
+func (m *MockTaskRepository) CompleteParent(ctx context.Context, id string) (*entity.Task, bool, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Bool(1), args.Error(2)
+	}
+	return args.Get(0).(*entity.Task), args.Bool(1), args.Error(2)
+}
+
+func (m *MockTaskRepository) CancelChildren(ctx context.Context, parentID string) ([]*entity.Task, error) {
+	args := m.Called(ctx, parentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*entity.Task), args.Error(1)
+}
+
+func (m *MockTaskRepository) DetachChildren(ctx context.Context, parentID string) ([]*entity.Task, error) {
+	args := m.Called(ctx, parentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*entity.Task), args.Error(1)
+}
+
+// testWorkerConfig возвращает конфигурацию воркеров для тестов
+func testWorkerConfig() config.WorkerConfig {
+	return config.WorkerConfig{
+		ID:                "test-worker",
+		Concurrency:       1,
+		QueueSize:         10,
+		PollInterval:      time.Hour,
+		LeaseDuration:     time.Minute,
+		ReapInterval:      time.Hour,
+		TaskTimeout:       time.Minute,
+		SchedulerInterval: time.Hour,
+		PriorityAging:     time.Minute,
+		IdempotencyWindow: time.Hour,
+		Queues:            []config.QueueConfig{{Name: config.DefaultQueue, Workers: 1}},
+	}
+}
+
+// testClaimOptions возвращает параметры захвата задач, соответствующие testWorkerConfig
+func testClaimOptions() entity.ClaimOptions {
+	return entity.ClaimOptions{
+		WorkerID:      "test-worker",
+		Queue:         config.DefaultQueue,
+		LeaseDuration: time.Minute,
+		TaskTypes:     []string{"test"},
+		PriorityAging: time.Minute,
+	}
+}
+
+// testRetryPolicy возвращает политику повторов без случайного отклонения
+func testRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute}
+}
+
+// newTestTaskUseCase создает taskUseCase с одним воркером, тестовой конфигурацией
+// и обработчиком process, зарегистрированным под типом "test"
+func newTestTaskUseCase(repo *MockTaskRepository, process LongRunningTask, cfg config.WorkerConfig) *taskUseCase {
+	handlers := NewTaskHandlerRegistry()
+	if err := handlers.Register("test", process); err != nil {
+		panic(err)
+	}
+
+	webhooks := NewWebhookUseCase(new(MockWebhookRepository), repo, testWebhookConfig())
+	return NewTaskUseCase(repo, nil, webhooks, handlers, cfg, testRetryPolicy())
+}
+
+// newTestNoopTaskUseCase создает taskUseCase с testWorkerConfig и обработчиком типа "test", который ничего не делает
+func newTestNoopTaskUseCase(repo *MockTaskRepository) *taskUseCase {
+	noop := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		return nil, nil
+	}
+	return newTestTaskUseCase(repo, noop, testWorkerConfig())
+}
+
 // TestCreateTask тестирует создание задачи
 func TestCreateTask(t *testing.T) {
 	mockRepo := new(MockTaskRepository)
-	mockProcess := func(ctx context.Context) (json.RawMessage, error) {
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
 		return json.RawMessage(`{"result":"success"}`), nil
 	}
 
-	mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*entity.Task")).Return(nil)
-	mockRepo.On("GetByID", mock.Anything, "mock-id").Return(&entity.Task{ID: "mock-id"}, nil)
-	mockRepo.On("Update", mock.Anything, mock.AnythingOfType("*entity.Task")).Return(nil)
+	mockRepo.On("CountByStatus", mock.Anything, entity.TaskStatusPending).Return(0, nil)
+	mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*entity.Task")).Return(true, nil)
+	mockRepo.On("ReleaseExpired", mock.Anything).Return([]*entity.Task{}, nil)
+	mockRepo.On("PromoteDue", mock.Anything).Return([]*entity.Task{}, nil)
+	mockRepo.On("DeleteExpiredIdempotencyKeys", mock.Anything, time.Hour).Return(int64(0), nil)
+	mockRepo.On("ClaimNext", mock.Anything, testClaimOptions()).
+		Return(&entity.Task{ID: "mock-id", Type: "test", Status: entity.TaskStatusProcessing}, nil).Once()
+	mockRepo.On("ClaimNext", mock.Anything, testClaimOptions()).Return(nil, entity.ErrNoPendingTasks)
+	mockRepo.On("Update", mock.Anything, mock.AnythingOfType("*entity.Task"), entity.TaskStatusProcessing).Return(nil)
+	mockRepo.On("ReleaseDependents", mock.Anything, "mock-id").Return([]*entity.Task{}, nil)
 
-	useCase := NewTaskUseCase(mockRepo, mockProcess)
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+	useCase.Start(context.Background())
+	defer useCase.Stop(context.Background())
 
-	task, err := useCase.CreateTask(context.Background())
+	task, _, err := useCase.CreateTask(context.Background(), entity.TaskSpec{Type: "test"})
 
 	assert.NoError(t, err)
 	assert.NotNil(t, task)
@@ -76,14 +300,16 @@ func TestCreateTask(t *testing.T) {
 	time.Sleep(100 * time.Millisecond)
 
 	mockRepo.AssertCalled(t, "Create", mock.Anything, mock.AnythingOfType("*entity.Task"))
-	mockRepo.AssertCalled(t, "GetByID", mock.Anything, "mock-id")
-	mockRepo.AssertCalled(t, "Update", mock.Anything, mock.AnythingOfType("*entity.Task"))
+	mockRepo.AssertCalled(t, "ClaimNext", mock.Anything, testClaimOptions())
+	mockRepo.AssertCalled(t, "Update", mock.Anything, mock.MatchedBy(func(task *entity.Task) bool {
+		return task.ID == "mock-id" && task.Status == entity.TaskStatusCompleted
+	}), entity.TaskStatusProcessing)
 }
 
 // TestGetTaskByID тестирует получение задачи по ID
 func TestGetTaskByID(t *testing.T) {
 	mockRepo := new(MockTaskRepository)
-	mockProcess := func(ctx context.Context) (json.RawMessage, error) {
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
 		return nil, nil
 	}
 
@@ -91,7 +317,7 @@ func TestGetTaskByID(t *testing.T) {
 
 	mockRepo.On("GetByID", mock.Anything, "test-id").Return(expectedTask, nil)
 
-	useCase := NewTaskUseCase(mockRepo, mockProcess)
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
 
 	task, err := useCase.GetTaskByID(context.Background(), "test-id")
 
@@ -103,13 +329,13 @@ func TestGetTaskByID(t *testing.T) {
 // TestGetTaskByID_Error тестирует ошибку при получении задачи
 func TestGetTaskByID_Error(t *testing.T) {
 	mockRepo := new(MockTaskRepository)
-	mockProcess := func(ctx context.Context) (json.RawMessage, error) {
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
 		return json.RawMessage(`{"result":"success"}`), nil
 	}
 
 	mockRepo.On("GetByID", mock.Anything, "task-id").Return(nil, errors.New("task not found"))
 
-	useCase := NewTaskUseCase(mockRepo, mockProcess)
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
 
 	task, err := useCase.GetTaskByID(context.Background(), "task-id")
 
@@ -122,7 +348,7 @@ func TestGetTaskByID_Error(t *testing.T) {
 // TestListTasks тестирует получение списка задач
 func TestListTasks(t *testing.T) {
 	mockRepo := new(MockTaskRepository)
-	mockProcess := func(ctx context.Context) (json.RawMessage, error) {
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
 		return json.RawMessage(`{"result":"success"}`), nil
 	}
 
@@ -142,14 +368,1020 @@ func TestListTasks(t *testing.T) {
 		},
 	}
 
-	mockRepo.On("List", mock.Anything, 10, 0).Return(expectedTasks, nil)
+	filter := entity.TaskFilter{Limit: 10, Offset: 0}
+	mockRepo.On("List", mock.Anything, filter).Return(expectedTasks, nil)
 
-	useCase := NewTaskUseCase(mockRepo, mockProcess)
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
 
-	tasks, err := useCase.ListTasks(context.Background(), 10, 0)
+	tasks, err := useCase.ListTasks(context.Background(), filter)
 
 	assert.NoError(t, err)
 	assert.Equal(t, expectedTasks, tasks)
 
 	mockRepo.AssertExpectations(t)
 }
+
+// TestCreateTask_QueueFull тестирует отказ в создании новой задачи при переполненной очереди
+func TestCreateTask_QueueFull(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		return nil, nil
+	}
+
+	mockRepo.On("CountByStatus", mock.Anything, entity.TaskStatusPending).Return(10, nil)
+
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+
+	task, _, err := useCase.CreateTask(context.Background(), entity.TaskSpec{Type: "test"})
+
+	assert.ErrorIs(t, err, ErrQueueFull)
+	assert.Nil(t, task)
+
+	mockRepo.On("HasIdempotencyKey", mock.Anything, "key-1", time.Hour).Return(false, nil)
+	mockRepo.On("HasUnfinished", mock.Anything, "test", "order-42").Return(false, nil)
+
+	task, _, err = useCase.CreateTask(context.Background(),
+		entity.TaskSpec{Type: "test", IdempotencyKey: "key-1", UniqueKey: "order-42"})
+
+	assert.ErrorIs(t, err, ErrQueueFull)
+	assert.Nil(t, task)
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "CreateIdempotent", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// TestExecuteTask_LeaseLost тестирует, что результат не сохраняется, если аренда задачи потеряна
+func TestExecuteTask_LeaseLost(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	mockRepo.On("ExtendLease", mock.Anything, "task-id", "test-worker", 30*time.Millisecond).Return(false, entity.ErrLeaseLost)
+
+	cfg := testWorkerConfig()
+	cfg.LeaseDuration = 30 * time.Millisecond
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, cfg)
+
+	useCase.executeTask(context.Background(), &entity.Task{ID: "task-id", Type: "test", Status: entity.TaskStatusProcessing})
+
+	mockRepo.AssertCalled(t, "ExtendLease", mock.Anything, "task-id", "test-worker", 30*time.Millisecond)
+	mockRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// TestExecuteTask_StaleResult тестирует, что результат задачи, статус которой успели изменить, отбрасывается
+func TestExecuteTask_StaleResult(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		return json.RawMessage(`{}`), nil
+	}
+
+	stale := fmt.Errorf("%w: task is cancelled, expected processing", entity.ErrInvalidTransition)
+	mockRepo.On("Update", mock.Anything, mock.AnythingOfType("*entity.Task"), entity.TaskStatusProcessing).Return(stale)
+
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+	events, unsubscribe := useCase.SubscribeTaskEvents("task-id")
+	defer unsubscribe()
+
+	useCase.executeTask(context.Background(), &entity.Task{ID: "task-id", Type: "test", Status: entity.TaskStatusProcessing})
+
+	select {
+	case event := <-events:
+		t.Fatalf("unexpected event for stale result: %s", event.Status)
+	default:
+	}
+	mockRepo.AssertNotCalled(t, "ReleaseDependents", mock.Anything, mock.Anything)
+}
+
+// TestExecuteTask_VersionConflict тестирует повторное сохранение результата после конфликта версий,
+// пока задачу выполняет та же попытка, и отказ от результата после перехвата задачи другим воркером
+func TestExecuteTask_VersionConflict(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		return json.RawMessage(`{}`), nil
+	}
+
+	conflict := fmt.Errorf("%w: task version is 5, expected 3", entity.ErrConflict)
+	withVersion := func(version int) interface{} {
+		return mock.MatchedBy(func(task *entity.Task) bool { return task.Version == version })
+	}
+	mockRepo.On("Update", mock.Anything, withVersion(3), entity.TaskStatusProcessing).Return(conflict).Once()
+	mockRepo.On("Update", mock.Anything, withVersion(5), entity.TaskStatusProcessing).Return(nil).Once()
+	mockRepo.On("GetByID", mock.Anything, "task-id").Return(&entity.Task{
+		ID: "task-id", Status: entity.TaskStatusProcessing, WorkerID: "worker-1", Attempts: 1, Version: 5,
+	}, nil).Once()
+	mockRepo.On("ReleaseDependents", mock.Anything, "task-id").Return([]*entity.Task{}, nil)
+
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+
+	task := &entity.Task{ID: "task-id", Type: "test", Status: entity.TaskStatusProcessing, WorkerID: "worker-1", Attempts: 1, Version: 3}
+	useCase.executeTask(context.Background(), task)
+
+	assert.Equal(t, entity.TaskStatusCompleted, task.Status)
+	mockRepo.AssertExpectations(t)
+
+	// Задача вернулась в очередь по истечении аренды и захвачена другим воркером
+	mockRepo.On("Update", mock.Anything, withVersion(5), entity.TaskStatusProcessing).Return(conflict).Once()
+	mockRepo.On("GetByID", mock.Anything, "task-id").Return(&entity.Task{
+		ID: "task-id", Status: entity.TaskStatusProcessing, WorkerID: "worker-2", Attempts: 2, Version: 7,
+	}, nil).Once()
+
+	task = &entity.Task{ID: "task-id", Type: "test", Status: entity.TaskStatusProcessing, WorkerID: "worker-1", Attempts: 1, Version: 5}
+	useCase.executeTask(context.Background(), task)
+
+	mockRepo.AssertNumberOfCalls(t, "Update", 3)
+	mockRepo.AssertNumberOfCalls(t, "ReleaseDependents", 1)
+}
+
+// TestReapExpiredLeases тестирует возврат в очередь задач с истекшей арендой и рассылку событий о них
+func TestReapExpiredLeases(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		return nil, nil
+	}
+
+	mockRepo.On("ReleaseExpired", mock.Anything).Return([]*entity.Task{
+		{ID: "task-1", Status: entity.TaskStatusPending},
+		{ID: "task-2", Status: entity.TaskStatusDead},
+	}, nil)
+	mockRepo.On("FailDependents", mock.Anything, "task-2").Return([]*entity.Task{}, nil)
+
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+	events, unsubscribe := useCase.SubscribeTaskEvents("task-2")
+	defer unsubscribe()
+
+	useCase.reapExpiredLeases(context.Background())
+
+	mockRepo.AssertExpectations(t)
+	event := <-events
+	assert.Equal(t, "task-2", event.TaskID)
+	assert.Equal(t, entity.TaskStatusDead, event.Status)
+}
+
+// TestCancelTask_Pending тестирует отмену ожидающей задачи
+func TestCancelTask_Pending(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		return nil, nil
+	}
+
+	cancelled := &entity.Task{ID: "task-id", Status: entity.TaskStatusCancelled}
+	mockRepo.On("RequestCancel", mock.Anything, "task-id", 0).Return(cancelled, nil)
+	mockRepo.On("FailDependents", mock.Anything, "task-id").Return([]*entity.Task{}, nil)
+	mockRepo.On("CancelChildren", mock.Anything, "task-id").Return([]*entity.Task{}, nil)
+
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+
+	task, err := useCase.CancelTask(context.Background(), "task-id", 0)
+
+	assert.NoError(t, err)
+	assert.Equal(t, entity.TaskStatusCancelled, task.Status)
+	mockRepo.AssertExpectations(t)
+}
+
+// TestCancelTask_Finished тестирует отказ в отмене завершенной задачи
+func TestCancelTask_Finished(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		return nil, nil
+	}
+
+	mockRepo.On("RequestCancel", mock.Anything, "task-id", 0).Return(nil, entity.ErrTaskFinished)
+
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+
+	task, err := useCase.CancelTask(context.Background(), "task-id", 0)
+
+	assert.ErrorIs(t, err, ErrTaskNotCancellable)
+	assert.Nil(t, task)
+}
+
+// TestCancelTask_Running тестирует отмену задачи, выполняемой на этой реплике
+func TestCancelTask_Running(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	started := make(chan struct{})
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	running := &entity.Task{ID: "task-id", Type: "test", Status: entity.TaskStatusProcessing}
+	mockRepo.On("RequestCancel", mock.Anything, "task-id", 0).Return(running, nil)
+	mockRepo.On("Update", mock.Anything, mock.AnythingOfType("*entity.Task"), entity.TaskStatusProcessing).Return(nil)
+	mockRepo.On("FailDependents", mock.Anything, "task-id").Return([]*entity.Task{}, nil)
+	mockRepo.On("CancelChildren", mock.Anything, "task-id").Return([]*entity.Task{}, nil)
+
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		useCase.executeTask(context.Background(), &entity.Task{ID: "task-id", Type: "test", Status: entity.TaskStatusProcessing})
+	}()
+
+	<-started
+	_, err := useCase.CancelTask(context.Background(), "task-id", 0)
+	assert.NoError(t, err)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("task was not cancelled")
+	}
+
+	mockRepo.AssertCalled(t, "Update", mock.Anything, mock.MatchedBy(func(task *entity.Task) bool {
+		return task.Status == entity.TaskStatusCancelled
+	}), entity.TaskStatusProcessing)
+}
+
+// TestExecuteTask_RetryScheduled тестирует повторное планирование задачи после ошибки
+func TestExecuteTask_RetryScheduled(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		return nil, errors.New("upstream unavailable")
+	}
+
+	mockRepo.On("Update", mock.Anything, mock.AnythingOfType("*entity.Task"), entity.TaskStatusProcessing).Return(nil)
+
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+
+	before := time.Now()
+	claimedAt := before.Add(-time.Second)
+	task := &entity.Task{ID: "task-id", Type: "test", Status: entity.TaskStatusProcessing, Attempts: 2, MaxAttempts: 3,
+		StartedAt: &claimedAt}
+	useCase.executeTask(context.Background(), task)
+
+	assert.Equal(t, entity.TaskStatusPending, task.Status)
+	assert.Equal(t, "upstream unavailable", task.Error)
+	assert.WithinDuration(t, before.Add(2*time.Second), task.NextRunAt, 500*time.Millisecond)
+	assert.Len(t, task.AttemptHistory, 1)
+	assert.Equal(t, 2, task.AttemptHistory[0].Attempt)
+	assert.Equal(t, claimedAt, task.AttemptHistory[0].StartedAt)
+	assert.Equal(t, "upstream unavailable", task.AttemptHistory[0].Error)
+}
+
+// TestExecuteTask_RetriesExhausted тестирует перевод задачи в dead после последней попытки
+func TestExecuteTask_RetriesExhausted(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		return nil, errors.New("upstream unavailable")
+	}
+
+	mockRepo.On("Update", mock.Anything, mock.AnythingOfType("*entity.Task"), entity.TaskStatusProcessing).Return(nil)
+	mockRepo.On("FailDependents", mock.Anything, "task-id").Return([]*entity.Task{}, nil)
+
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+
+	task := &entity.Task{
+		ID:             "task-id",
+		Type:           "test",
+		Status:         entity.TaskStatusProcessing,
+		Attempts:       3,
+		MaxAttempts:    3,
+		AttemptHistory: entity.TaskAttempts{{Attempt: 1, Error: "timeout"}, {Attempt: 2, Error: "timeout"}},
+	}
+	useCase.executeTask(context.Background(), task)
+
+	assert.Equal(t, entity.TaskStatusDead, task.Status)
+	assert.Len(t, task.AttemptHistory, 3)
+	mockRepo.AssertExpectations(t)
+}
+
+// TestRetryTask тестирует повторную постановку в очередь исчерпавшей попытки задачи
+func TestRetryTask(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		return nil, nil
+	}
+
+	requeued := &entity.Task{ID: "task-id", Status: entity.TaskStatusPending, Attempts: 0}
+	mockRepo.On("CountByStatus", mock.Anything, entity.TaskStatusPending).Return(0, nil)
+	mockRepo.On("Requeue", mock.Anything, "task-id", 0).Return(requeued, nil)
+
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+
+	task, err := useCase.RetryTask(context.Background(), "task-id", 0)
+
+	assert.NoError(t, err)
+	assert.Equal(t, requeued, task)
+	mockRepo.AssertExpectations(t)
+}
+
+// TestRetryTask_VersionConflict тестирует отказ в повторе задачи, версия которой не совпала с ожидаемой
+func TestRetryTask_VersionConflict(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		return nil, nil
+	}
+
+	conflict := fmt.Errorf("%w: task version is 4, expected 3", entity.ErrConflict)
+	mockRepo.On("CountByStatus", mock.Anything, entity.TaskStatusPending).Return(0, nil)
+	mockRepo.On("Requeue", mock.Anything, "task-id", 3).Return(nil, conflict)
+
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+
+	task, err := useCase.RetryTask(context.Background(), "task-id", 3)
+
+	assert.ErrorIs(t, err, entity.ErrConflict)
+	assert.Nil(t, task)
+	mockRepo.AssertExpectations(t)
+}
+
+// TestRetryTask_Dependencies тестирует повтор задачи, которая снова ждет незавершенную зависимость,
+// и отказ в повторе задачи, зависимость которой не выполнилась
+func TestRetryTask_Dependencies(t *testing.T) {
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		return nil, nil
+	}
+
+	t.Run("dependency unfinished", func(t *testing.T) {
+		mockRepo := new(MockTaskRepository)
+		blocked := &entity.Task{ID: "task-id", Status: entity.TaskStatusBlocked}
+		mockRepo.On("CountByStatus", mock.Anything, entity.TaskStatusPending).Return(0, nil)
+		mockRepo.On("Requeue", mock.Anything, "task-id", 0).Return(blocked, nil)
+
+		useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+
+		task, err := useCase.RetryTask(context.Background(), "task-id", 0)
+
+		assert.NoError(t, err)
+		assert.Equal(t, entity.TaskStatusBlocked, task.Status)
+	})
+
+	t.Run("dependency failed", func(t *testing.T) {
+		mockRepo := new(MockTaskRepository)
+		failed := fmt.Errorf("%w: dependency dep-id is dead, retry it first", entity.ErrInvalidTransition)
+		mockRepo.On("CountByStatus", mock.Anything, entity.TaskStatusPending).Return(0, nil)
+		mockRepo.On("Requeue", mock.Anything, "task-id", 0).Return(nil, failed)
+
+		useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+
+		task, err := useCase.RetryTask(context.Background(), "task-id", 0)
+
+		assert.ErrorIs(t, err, entity.ErrInvalidTransition)
+		assert.ErrorContains(t, err, "dep-id")
+		assert.Nil(t, task)
+	})
+}
+
+// TestRetryTask_NotRetryable тестирует отказ в повторе задачи, которая еще выполняется
+func TestRetryTask_NotRetryable(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		return nil, nil
+	}
+
+	mockRepo.On("CountByStatus", mock.Anything, entity.TaskStatusPending).Return(0, nil)
+	mockRepo.On("Requeue", mock.Anything, "task-id", 0).Return(nil, entity.ErrUnexpectedStatus)
+
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+
+	task, err := useCase.RetryTask(context.Background(), "task-id", 0)
+
+	assert.ErrorIs(t, err, ErrTaskNotRetryable)
+	assert.Nil(t, task)
+}
+
+// TestCreateTask_UnknownType тестирует отказ в создании задачи незарегистрированного типа
+func TestCreateTask_UnknownType(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		return nil, nil
+	}
+
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+
+	task, _, err := useCase.CreateTask(context.Background(), entity.TaskSpec{Type: "email.send"})
+
+	assert.ErrorIs(t, err, ErrUnknownTaskType)
+	assert.Nil(t, task)
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+// TestExecuteTask_Timeout тестирует прерывание задачи, превысившей собственное время выполнения
+func TestExecuteTask_Timeout(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	mockRepo.On("Update", mock.Anything, mock.AnythingOfType("*entity.Task"), entity.TaskStatusProcessing).Return(nil)
+	mockRepo.On("FailDependents", mock.Anything, "task-id").Return([]*entity.Task{}, nil)
+
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+
+	task := &entity.Task{
+		ID:             "task-id",
+		Type:           "test",
+		Status:         entity.TaskStatusProcessing,
+		Attempts:       1,
+		MaxAttempts:    3,
+		TimeoutSeconds: 1,
+	}
+	useCase.executeTask(context.Background(), task)
+
+	assert.Equal(t, entity.TaskStatusFailed, task.Status)
+	assert.Equal(t, entity.ErrorCodeTimeout, task.ErrorCode)
+}
+
+// TestExecuteTask_ShutdownAfterSuccess тестирует сохранение результата обработчика,
+// успевшего завершиться до остановки воркера
+func TestExecuteTask_ShutdownAfterSuccess(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	workerCtx, stopWorker := context.WithCancel(context.Background())
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		stopWorker()
+		return json.RawMessage(`{"sent":true}`), nil
+	}
+
+	mockRepo.On("Update", mock.Anything, mock.AnythingOfType("*entity.Task"), entity.TaskStatusProcessing).Return(nil)
+	mockRepo.On("ReleaseDependents", mock.Anything, "task-id").Return([]*entity.Task{}, nil)
+
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+
+	task := &entity.Task{ID: "task-id", Type: "test", Status: entity.TaskStatusProcessing, Attempts: 1, MaxAttempts: 3}
+	useCase.executeTask(workerCtx, task)
+
+	assert.Equal(t, entity.TaskStatusCompleted, task.Status)
+	assert.JSONEq(t, `{"sent":true}`, string(task.Result))
+	mockRepo.AssertExpectations(t)
+}
+
+// TestCreateTask_InvalidTimeout тестирует отказ в создании задачи с отрицательным
+// или превышающим MaxTaskTimeoutSeconds временем выполнения
+func TestCreateTask_InvalidTimeout(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		return nil, nil
+	}
+
+	mockRepo.On("CountByStatus", mock.Anything, entity.TaskStatusPending).Return(0, nil)
+	mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*entity.Task")).Return(true, nil)
+
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+
+	for _, timeout := range []int{-1, entity.MaxTaskTimeoutSeconds + 1} {
+		task, _, err := useCase.CreateTask(context.Background(), entity.TaskSpec{Type: "test", TimeoutSeconds: timeout})
+
+		assert.ErrorIs(t, err, ErrInvalidTaskSpec)
+		assert.Nil(t, task)
+	}
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+
+	task, created, err := useCase.CreateTask(context.Background(),
+		entity.TaskSpec{Type: "test", TimeoutSeconds: entity.MaxTaskTimeoutSeconds})
+
+	assert.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, entity.MaxTaskTimeoutSeconds, task.TimeoutSeconds)
+}
+
+// TestCreateTask_ReservedIdempotencyKey тестирует отказ в ключе идемпотентности из пространства ключей расписаний
+func TestCreateTask_ReservedIdempotencyKey(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		return nil, nil
+	}
+
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+
+	task, _, err := useCase.CreateTask(context.Background(), entity.TaskSpec{
+		Type:           "test",
+		IdempotencyKey: entity.ScheduleIdempotencyKey("schedule-id", time.Unix(1700000000, 0)),
+	})
+
+	assert.ErrorIs(t, err, ErrInvalidTaskSpec)
+	assert.Nil(t, task)
+	mockRepo.AssertNotCalled(t, "CreateIdempotent", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// TestCreateTask_Delayed тестирует создание отложенной задачи без проверки лимита очереди
+func TestCreateTask_Delayed(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		return nil, nil
+	}
+
+	mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*entity.Task")).Return(true, nil)
+
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+
+	before := time.Now()
+	task, _, err := useCase.CreateTask(context.Background(), entity.TaskSpec{Type: "test", DelaySeconds: 60})
+
+	assert.NoError(t, err)
+	assert.Equal(t, entity.TaskStatusScheduled, task.Status)
+	assert.WithinDuration(t, before.Add(time.Minute), task.NextRunAt, time.Second)
+	mockRepo.AssertNotCalled(t, "CountByStatus", mock.Anything, mock.Anything)
+}
+
+// TestCreateTask_RunAtAndDelay тестирует отказ при одновременном указании run_at и delay_seconds
+func TestCreateTask_RunAtAndDelay(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		return nil, nil
+	}
+
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+
+	runAt := time.Now().Add(time.Hour)
+	task, _, err := useCase.CreateTask(context.Background(), entity.TaskSpec{Type: "test", RunAt: &runAt, DelaySeconds: 60})
+
+	assert.ErrorIs(t, err, ErrInvalidTaskSpec)
+	assert.Nil(t, task)
+}
+
+// TestCreateTask_Priority тестирует передачу приоритета в создаваемую задачу и проверку его диапазона
+func TestCreateTask_Priority(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		return nil, nil
+	}
+
+	mockRepo.On("CountByStatus", mock.Anything, entity.TaskStatusPending).Return(0, nil)
+	mockRepo.On("Create", mock.Anything, mock.MatchedBy(func(task *entity.Task) bool {
+		return task.Priority == 7
+	})).Return(true, nil)
+
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+
+	task, _, err := useCase.CreateTask(context.Background(), entity.TaskSpec{Type: "test", Priority: 7})
+	assert.NoError(t, err)
+	assert.Equal(t, 7, task.Priority)
+
+	_, _, err = useCase.CreateTask(context.Background(), entity.TaskSpec{Type: "test", Priority: entity.MaxTaskPriority + 1})
+	assert.ErrorIs(t, err, ErrInvalidTaskSpec)
+	mockRepo.AssertNumberOfCalls(t, "Create", 1)
+}
+
+// TestCreateTask_Queue тестирует маршрутизацию задачи в очередь по умолчанию и отказ для неизвестной очереди
+func TestCreateTask_Queue(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		return nil, nil
+	}
+
+	mockRepo.On("CountByStatus", mock.Anything, entity.TaskStatusPending).Return(0, nil)
+	mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*entity.Task")).Return(true, nil)
+
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+
+	task, _, err := useCase.CreateTask(context.Background(), entity.TaskSpec{Type: "test"})
+	assert.NoError(t, err)
+	assert.Equal(t, config.DefaultQueue, task.Queue)
+
+	_, _, err = useCase.CreateTask(context.Background(), entity.TaskSpec{Type: "test", Queue: "missing"})
+	assert.ErrorIs(t, err, ErrUnknownQueue)
+	mockRepo.AssertNumberOfCalls(t, "Create", 1)
+}
+
+// TestCreateTask_IdempotencyKey тестирует возврат ранее созданной задачи при повторе запроса
+// с тем же ключом без проверки лимита очереди и отказ для другого запроса с этим ключом
+func TestCreateTask_IdempotencyKey(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		return nil, nil
+	}
+
+	spec := entity.TaskSpec{Type: "test", Payload: json.RawMessage(`{"to":"user@example.com"}`), IdempotencyKey: "key-1"}
+	requestHash, err := specHash(spec)
+	assert.NoError(t, err)
+
+	existing := &entity.Task{ID: "existing-id", Type: "test", Status: entity.TaskStatusProcessing}
+	stored := &entity.IdempotencyKey{Key: "key-1", RequestHash: requestHash, TaskID: existing.ID}
+
+	mockRepo.On("HasIdempotencyKey", mock.Anything, "key-1", time.Hour).Return(true, nil)
+	mockRepo.On("CreateIdempotent", mock.Anything, mock.AnythingOfType("*entity.Task"), "key-1", requestHash, time.Hour).
+		Return(stored, false, nil)
+	mockRepo.On("CreateIdempotent", mock.Anything, mock.AnythingOfType("*entity.Task"), "key-1", mock.Anything, time.Hour).
+		Return(stored, false, nil)
+	mockRepo.On("GetByID", mock.Anything, existing.ID).Return(existing, nil)
+
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+
+	task, created, err := useCase.CreateTask(context.Background(), spec)
+	assert.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, existing, task)
+
+	spec.Payload = json.RawMessage(`{"to":"other@example.com"}`)
+	_, _, err = useCase.CreateTask(context.Background(), spec)
+	assert.ErrorIs(t, err, ErrIdempotencyKeyReused)
+
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "CountByStatus", mock.Anything, mock.Anything)
+}
+
+// TestCreateTask_UniqueKey тестирует вычисление ключа уникальности и возврат незавершенной задачи с тем же ключом
+// без проверки лимита очереди
+func TestCreateTask_UniqueKey(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		return nil, nil
+	}
+
+	spec := entity.TaskSpec{Type: "test", Payload: json.RawMessage(`{"to": "user@example.com"}`), Unique: true}
+	compacted := spec
+	compacted.Payload = json.RawMessage(`{"to":"user@example.com"}`)
+	assert.Equal(t, uniqueKey(spec), uniqueKey(compacted))
+	assert.NotEqual(t, uniqueKey(spec), uniqueKey(entity.TaskSpec{Type: "other", Payload: spec.Payload, Unique: true}))
+	assert.Equal(t, "order-42", uniqueKey(entity.TaskSpec{Type: "test", UniqueKey: "order-42", Unique: true}))
+	assert.Empty(t, uniqueKey(entity.TaskSpec{Type: "test", Payload: spec.Payload}))
+
+	mockRepo.On("HasUnfinished", mock.Anything, "test", uniqueKey(spec)).Return(true, nil)
+	mockRepo.On("Create", mock.Anything, mock.MatchedBy(func(task *entity.Task) bool {
+		return task.UniqueKey == uniqueKey(spec)
+	})).Return(false, nil)
+
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+	events, unsubscribe := useCase.SubscribeTaskEvents("")
+	defer unsubscribe()
+
+	task, created, err := useCase.CreateTask(context.Background(), spec)
+	assert.NoError(t, err)
+	assert.False(t, created)
+	assert.NotNil(t, task)
+	assert.Empty(t, events)
+	mockRepo.AssertNotCalled(t, "CountByStatus", mock.Anything, mock.Anything)
+}
+
+// TestCreateTasks тестирует создание пачки задач одним вызовом репозитория
+// и отказ создавать пачку, если хотя бы одна задача некорректна
+func TestCreateTasks(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		return nil, nil
+	}
+
+	mockRepo.On("CountByStatus", mock.Anything, entity.TaskStatusPending).Return(8, nil)
+	mockRepo.On("CreateMany", mock.Anything, mock.MatchedBy(func(tasks []*entity.Task) bool {
+		return len(tasks) == 2 && tasks[0].Status == entity.TaskStatusPending && tasks[1].Status == entity.TaskStatusScheduled
+	})).Return("batch-id", nil)
+
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+
+	batch, err := useCase.CreateTasks(context.Background(), []entity.TaskSpec{
+		{Type: "test"},
+		{Type: "test", DelaySeconds: 60},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, &entity.TaskBatch{ID: "batch-id", TaskIDs: []string{"mock-id-0", "mock-id-1"}}, batch)
+
+	// Три ожидающие задачи не помещаются в очередь, где из 10 мест уже занято 8
+	_, err = useCase.CreateTasks(context.Background(), []entity.TaskSpec{{Type: "test"}, {Type: "test"}, {Type: "test"}})
+	assert.ErrorIs(t, err, ErrQueueFull)
+
+	_, err = useCase.CreateTasks(context.Background(), []entity.TaskSpec{{Type: "test"}, {Type: "test", Priority: -1}})
+	assert.ErrorIs(t, err, ErrInvalidTaskSpec)
+
+	_, err = useCase.CreateTasks(context.Background(), nil)
+	assert.ErrorIs(t, err, ErrInvalidTaskSpec)
+
+	mockRepo.AssertNumberOfCalls(t, "CreateMany", 1)
+}
+
+// TestCreateTask_Dependencies тестирует передачу зависимостей без повторов и отказ для несуществующей зависимости
+func TestCreateTask_Dependencies(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		return nil, nil
+	}
+
+	mockRepo.On("CountByStatus", mock.Anything, entity.TaskStatusPending).Return(0, nil)
+	mockRepo.On("Create", mock.Anything, mock.MatchedBy(func(task *entity.Task) bool {
+		return assert.ObjectsAreEqual([]string{"parent-a", "parent-c"}, task.DependsOn)
+	})).Return(true, nil).Once()
+	mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*entity.Task")).Return(false, entity.ErrDependencyNotFound)
+
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+
+	_, created, err := useCase.CreateTask(context.Background(),
+		entity.TaskSpec{Type: "test", DependsOn: []string{"parent-a", "parent-c", "parent-a"}})
+	assert.NoError(t, err)
+	assert.True(t, created)
+
+	_, _, err = useCase.CreateTask(context.Background(), entity.TaskSpec{Type: "test", DependsOn: []string{"missing"}})
+	assert.ErrorIs(t, err, ErrInvalidTaskSpec)
+	assert.ErrorIs(t, err, entity.ErrDependencyNotFound)
+
+	_, _, err = useCase.CreateTask(context.Background(), entity.TaskSpec{Type: "test", DependsOn: []string{""}})
+	assert.ErrorIs(t, err, ErrInvalidTaskSpec)
+}
+
+// TestResolveDependents тестирует запуск зависимых задач после успешного завершения задачи
+// и их отказ после ее падения
+func TestResolveDependents(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		return nil, nil
+	}
+
+	released := &entity.Task{ID: "child-id", Queue: config.DefaultQueue, Status: entity.TaskStatusPending}
+	failed := &entity.Task{ID: "child-id", Status: entity.TaskStatusFailed, ErrorCode: entity.ErrorCodeDependencyFailed}
+	mockRepo.On("ReleaseDependents", mock.Anything, "completed-id").Return([]*entity.Task{released}, nil)
+	mockRepo.On("FailDependents", mock.Anything, "dead-id").Return([]*entity.Task{failed}, nil)
+
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+	events, unsubscribe := useCase.SubscribeTaskEvents("child-id")
+	defer unsubscribe()
+
+	useCase.resolveDependents(context.Background(), &entity.Task{ID: "completed-id", Status: entity.TaskStatusCompleted})
+	assert.Equal(t, entity.TaskStatusPending, (<-events).Status)
+
+	useCase.resolveDependents(context.Background(), &entity.Task{ID: "dead-id", Status: entity.TaskStatusDead})
+	event := <-events
+	assert.Equal(t, entity.TaskStatusFailed, event.Status)
+	assert.Equal(t, entity.ErrorCodeDependencyFailed, event.ErrorCode)
+
+	useCase.resolveDependents(context.Background(), &entity.Task{ID: "pending-id", Status: entity.TaskStatusPending})
+	mockRepo.AssertExpectations(t)
+}
+
+// TestExecuteTask_SpawnChildren тестирует ожидание дочерних задач и завершение родителя вместе с последней из них
+func TestExecuteTask_SpawnChildren(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		children, err := SpawnChildren(ctx, entity.TaskSpec{Type: "test"}, entity.TaskSpec{Type: "test"})
+		if err != nil {
+			return nil, err
+		}
+		if len(children) != 2 || *children[0].ParentID != "parent-id" {
+			return nil, errors.New("unexpected children")
+		}
+		return json.RawMessage(`{"files":2}`), nil
+	}
+
+	parentID := "parent-id"
+	completed := &entity.Task{ID: parentID, Status: entity.TaskStatusCompleted}
+	mockRepo.On("CountByStatus", mock.Anything, entity.TaskStatusPending).Return(0, nil)
+	mockRepo.On("CreateMany", mock.Anything, mock.Anything).Return("batch-id", nil)
+	mockRepo.On("Update", mock.Anything, mock.AnythingOfType("*entity.Task"), entity.TaskStatusProcessing).Return(nil)
+	mockRepo.On("CompleteParent", mock.Anything, parentID).Return(nil, false, nil).Once()
+	mockRepo.On("CompleteParent", mock.Anything, parentID).Return(completed, true, nil).Once()
+	mockRepo.On("ReleaseDependents", mock.Anything, "mock-id-1").Return([]*entity.Task{}, nil)
+	mockRepo.On("ReleaseDependents", mock.Anything, parentID).Return([]*entity.Task{}, nil)
+
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+
+	task := &entity.Task{ID: parentID, Type: "test", Status: entity.TaskStatusProcessing, Attempts: 1, MaxAttempts: 3}
+	useCase.executeTask(context.Background(), task)
+
+	assert.Equal(t, entity.TaskStatusBlocked, task.Status)
+	assert.JSONEq(t, `{"files":2}`, string(task.Result))
+
+	events, unsubscribe := useCase.SubscribeTaskEvents(parentID)
+	defer unsubscribe()
+	child := &entity.Task{ID: "mock-id-1", ParentID: &parentID, Status: entity.TaskStatusCompleted}
+	useCase.resolveDependents(context.Background(), child)
+
+	assert.Equal(t, entity.TaskStatusCompleted, (<-events).Status)
+	mockRepo.AssertExpectations(t)
+
+	_, err := SpawnChildren(context.Background(), entity.TaskSpec{Type: "test"})
+	assert.ErrorIs(t, err, ErrNotInTask)
+}
+
+// TestExecuteTask_SpawnChildrenThenFail тестирует, что дочерние задачи попытки, упавшей после их создания,
+// отвязываются от родителя и отменяются, чтобы повторная попытка не собрала их результаты
+func TestExecuteTask_SpawnChildrenThenFail(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		if _, err := SpawnChildren(ctx, entity.TaskSpec{Type: "test"}); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("processing failed")
+	}
+
+	mockRepo.On("CountByStatus", mock.Anything, entity.TaskStatusPending).Return(0, nil)
+	mockRepo.On("CreateMany", mock.Anything, mock.Anything).Return("batch-id", nil)
+	mockRepo.On("Update", mock.Anything, mock.AnythingOfType("*entity.Task"), entity.TaskStatusProcessing).Return(nil)
+	mockRepo.On("DetachChildren", mock.Anything, "parent-id").Return([]*entity.Task{
+		{ID: "mock-id-0", Status: entity.TaskStatusCancelled},
+	}, nil)
+	mockRepo.On("CancelChildren", mock.Anything, "mock-id-0").Return([]*entity.Task{}, nil)
+	mockRepo.On("FailDependents", mock.Anything, "mock-id-0").Return([]*entity.Task{}, nil)
+
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+
+	task := &entity.Task{ID: "parent-id", Type: "test", Status: entity.TaskStatusProcessing, Attempts: 1, MaxAttempts: 3}
+	useCase.executeTask(context.Background(), task)
+
+	assert.Equal(t, entity.TaskStatusPending, task.Status)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "CompleteParent", mock.Anything, mock.Anything)
+}
+
+// TestCancelTask_WithChildren тестирует отмену незавершенных дочерних задач вместе с родительской,
+// которая ждет их в статусе blocked, и то, что дочерняя задача не завершает уже отмененного родителя
+func TestCancelTask_WithChildren(t *testing.T) {
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		return nil, nil
+	}
+	parentID := "parent-id"
+
+	t.Run("cascade", func(t *testing.T) {
+		mockRepo := new(MockTaskRepository)
+		cancelled := &entity.Task{ID: parentID, Status: entity.TaskStatusCancelled}
+		children := []*entity.Task{
+			{ID: "child-1", ParentID: &parentID, Status: entity.TaskStatusCancelled},
+			{ID: "child-2", ParentID: &parentID, Status: entity.TaskStatusProcessing, CancelRequested: true},
+		}
+		mockRepo.On("RequestCancel", mock.Anything, parentID, 0).Return(cancelled, nil)
+		mockRepo.On("CancelChildren", mock.Anything, parentID).Return(children, nil)
+		mockRepo.On("CancelChildren", mock.Anything, "child-1").Return([]*entity.Task{}, nil)
+		mockRepo.On("FailDependents", mock.Anything, mock.Anything).Return([]*entity.Task{}, nil)
+		mockRepo.On("CompleteParent", mock.Anything, parentID).Return(nil, false, nil)
+
+		useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+		events, unsubscribe := useCase.SubscribeTaskEvents("")
+		defer unsubscribe()
+
+		_, err := useCase.CancelTask(context.Background(), parentID, 0)
+		assert.NoError(t, err)
+
+		published := make(map[string]entity.TaskStatus)
+		for len(events) > 0 {
+			event := <-events
+			published[event.TaskID] = event.Status
+		}
+		assert.Equal(t, map[string]entity.TaskStatus{
+			parentID:  entity.TaskStatusCancelled,
+			"child-1": entity.TaskStatusCancelled,
+			"child-2": entity.TaskStatusProcessing,
+		}, published)
+		mockRepo.AssertExpectations(t)
+		mockRepo.AssertNotCalled(t, "CancelChildren", mock.Anything, "child-2")
+	})
+
+	t.Run("child finished after parent cancelled", func(t *testing.T) {
+		mockRepo := new(MockTaskRepository)
+		mockRepo.On("CompleteParent", mock.Anything, parentID).Return(nil, false, nil)
+		mockRepo.On("ReleaseDependents", mock.Anything, "child-2").Return([]*entity.Task{}, nil)
+
+		useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+		events, unsubscribe := useCase.SubscribeTaskEvents(parentID)
+		defer unsubscribe()
+
+		child := &entity.Task{ID: "child-2", ParentID: &parentID, Status: entity.TaskStatusCompleted}
+		useCase.resolveDependents(context.Background(), child)
+
+		assert.Empty(t, events)
+		mockRepo.AssertNotCalled(t, "ReleaseDependents", mock.Anything, parentID)
+		mockRepo.AssertNotCalled(t, "FailDependents", mock.Anything, parentID)
+	})
+}
+
+// TestGetTaskChildren тестирует подсчет дочерних задач по статусам
+func TestGetTaskChildren(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		return nil, nil
+	}
+
+	counts := map[entity.TaskStatus]int{entity.TaskStatusCompleted: 3, entity.TaskStatusPending: 2}
+	children := []*entity.Task{{ID: "child-1"}, {ID: "child-2"}}
+	mockRepo.On("GetByID", mock.Anything, "parent-id").Return(&entity.Task{ID: "parent-id"}, nil)
+	mockRepo.On("GetByID", mock.Anything, "missing-id").Return(nil, entity.ErrTaskNotFound)
+	mockRepo.On("CountChildren", mock.Anything, "parent-id").Return(counts, nil)
+	mockRepo.On("List", mock.Anything, entity.TaskFilter{ParentID: "parent-id", Limit: 2, Offset: 0}).Return(children, nil)
+
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+
+	result, err := useCase.GetTaskChildren(context.Background(), "parent-id", 2, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, result.Total)
+	assert.Equal(t, counts, result.Counts)
+	assert.Equal(t, children, result.Tasks)
+
+	_, err = useCase.GetTaskChildren(context.Background(), "missing-id", 2, 0)
+	assert.ErrorIs(t, err, entity.ErrTaskNotFound)
+	mockRepo.AssertNotCalled(t, "CountChildren", mock.Anything, "missing-id")
+}
+
+// TestProcessNextTask_QueueLimits тестирует передачу имени очереди и лимита выполняемых задач при захвате
+func TestProcessNextTask_QueueLimits(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		return nil, nil
+	}
+
+	cfg := testWorkerConfig()
+	reports := config.QueueConfig{Name: "reports", Workers: 1, MaxInFlight: 2}
+	cfg.Queues = append(cfg.Queues, reports)
+
+	opts := testClaimOptions()
+	opts.Queue = "reports"
+	opts.MaxInFlight = 2
+	mockRepo.On("ClaimNext", mock.Anything, opts).Return(nil, entity.ErrNoPendingTasks)
+
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, cfg)
+
+	assert.False(t, useCase.processNextTask(context.Background(), reports))
+	mockRepo.AssertExpectations(t)
+}
+
+// TestListQueues тестирует объединение статистики из БД с настроенными очередями
+func TestListQueues(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		return nil, nil
+	}
+
+	cfg := testWorkerConfig()
+	cfg.Queues = append(cfg.Queues, config.QueueConfig{Name: "reports", Workers: 2, MaxInFlight: 3})
+
+	mockRepo.On("QueueStats", mock.Anything).Return([]*entity.QueueStats{
+		{Name: "reports", Depth: 5, Running: 1, OldestPendingAgeSeconds: 42},
+		{Name: "legacy", Depth: 1},
+	}, nil)
+
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, cfg)
+
+	queues, err := useCase.ListQueues(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []*entity.QueueStats{
+		{Name: config.DefaultQueue, Workers: 1},
+		{Name: "reports", Workers: 2, MaxInFlight: 3, Depth: 5, Running: 1, OldestPendingAgeSeconds: 42},
+	}, queues)
+}
+
+// TestExecuteTask_ReportProgress тестирует сохранение прогресса, опубликованного обработчиком задачи
+func TestExecuteTask_ReportProgress(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		if err := ReportProgress(ctx, 150, "too much"); !errors.Is(err, ErrInvalidProgress) {
+			return nil, err
+		}
+		if err := ReportProgress(ctx, 40, "halfway there"); err != nil {
+			return nil, err
+		}
+		return json.RawMessage(`{}`), nil
+	}
+
+	mockRepo.On("UpdateProgress", mock.Anything, "task-id", "test-worker", 40, "halfway there").Return(nil).Once()
+	mockRepo.On("Update", mock.Anything, mock.AnythingOfType("*entity.Task"), entity.TaskStatusProcessing).Return(nil)
+	mockRepo.On("ReleaseDependents", mock.Anything, "task-id").Return([]*entity.Task{}, nil)
+
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+
+	task := &entity.Task{ID: "task-id", Type: "test", Status: entity.TaskStatusProcessing, Attempts: 1, MaxAttempts: 3}
+	useCase.executeTask(context.Background(), task)
+
+	assert.Equal(t, entity.TaskStatusCompleted, task.Status)
+	assert.Equal(t, 100, task.Progress)
+	mockRepo.AssertExpectations(t)
+}
+
+// TestReportProgress_OutsideTask тестирует, что вне обработчика задачи публикация прогресса ничего не делает
+func TestReportProgress_OutsideTask(t *testing.T) {
+	assert.NoError(t, ReportProgress(context.Background(), 10, ""))
+}
+
+// TestWaitTask тестирует ожидание перехода задачи в конечный статус по событию
+func TestWaitTask(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		return nil, nil
+	}
+
+	mockRepo.On("GetByID", mock.Anything, "task-id").
+		Return(&entity.Task{ID: "task-id", Status: entity.TaskStatusProcessing}, nil).Once()
+	mockRepo.On("GetByID", mock.Anything, "task-id").
+		Return(&entity.Task{ID: "task-id", Status: entity.TaskStatusCompleted}, nil).Once()
+
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		useCase.events.Publish(entity.TaskEvent{TaskID: "task-id", Status: entity.TaskStatusProcessing, Progress: 50})
+		useCase.events.Publish(entity.TaskEvent{TaskID: "task-id", Status: entity.TaskStatusCompleted})
+	}()
+
+	task, err := useCase.WaitTask(context.Background(), "task-id", time.Minute)
+
+	assert.NoError(t, err)
+	assert.Equal(t, entity.TaskStatusCompleted, task.Status)
+	mockRepo.AssertExpectations(t)
+}
+
+// TestWaitTask_Timeout тестирует возврат текущего состояния задачи по истечении времени ожидания
+func TestWaitTask_Timeout(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	mockProcess := func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+		return nil, nil
+	}
+
+	mockRepo.On("GetByID", mock.Anything, "task-id").
+		Return(&entity.Task{ID: "task-id", Status: entity.TaskStatusPending}, nil)
+
+	useCase := newTestTaskUseCase(mockRepo, mockProcess, testWorkerConfig())
+
+	task, err := useCase.WaitTask(context.Background(), "task-id", 20*time.Millisecond)
+
+	assert.NoError(t, err)
+	assert.Equal(t, entity.TaskStatusPending, task.Status)
+	mockRepo.AssertNumberOfCalls(t, "GetByID", 2)
+}
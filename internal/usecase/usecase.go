@@ -2,23 +2,57 @@ package usecase
 
 import (
 	"context"
+	"time"
 
 	"github.com/Egorpalan/workmate-test/internal/entity"
 )
 
 type TaskUseCase interface {
-	CreateTask(ctx context.Context) (*entity.Task, error)
+	CreateTask(ctx context.Context, spec entity.TaskSpec) (*entity.Task, bool, error)
+	CreateTasks(ctx context.Context, specs []entity.TaskSpec) (*entity.TaskBatch, error)
+	GetTaskGraph(ctx context.Context, id string) (*entity.TaskGraph, error)
+	GetTaskChildren(ctx context.Context, id string, limit, offset int) (*entity.TaskChildren, error)
 	GetTaskByID(ctx context.Context, id string) (*entity.Task, error)
-	ListTasks(ctx context.Context, limit, offset int) ([]*entity.Task, error)
+	WaitTask(ctx context.Context, id string, timeout time.Duration) (*entity.Task, error)
+	ListTasks(ctx context.Context, filter entity.TaskFilter) ([]*entity.Task, error)
+	CancelTask(ctx context.Context, id string, version int) (*entity.Task, error)
+	RetryTask(ctx context.Context, id string, version int) (*entity.Task, error)
+	ListTaskTypes() []string
+	ListQueues(ctx context.Context) ([]*entity.QueueStats, error)
+	SubscribeTaskEvents(taskID string) (<-chan entity.TaskEvent, func())
+}
+
+type ScheduleUseCase interface {
+	CreateSchedule(ctx context.Context, spec entity.ScheduleSpec) (*entity.Schedule, error)
+	GetSchedule(ctx context.Context, id string) (*entity.Schedule, error)
+	ListSchedules(ctx context.Context, limit, offset int) ([]*entity.Schedule, error)
+	UpdateSchedule(ctx context.Context, id string, spec entity.ScheduleSpec) (*entity.Schedule, error)
+	DeleteSchedule(ctx context.Context, id string) error
+}
+
+type WebhookUseCase interface {
+	ListDeliveries(ctx context.Context, taskID string) ([]*entity.WebhookDelivery, error)
+}
+
+type WorkflowUseCase interface {
+	CreateWorkflow(ctx context.Context, spec entity.WorkflowSpec) (*entity.Workflow, error)
+	GetWorkflow(ctx context.Context, id string) (*entity.Workflow, error)
+	CancelWorkflow(ctx context.Context, id string) (*entity.Workflow, error)
 }
 
 type UseCase struct {
-	Task TaskUseCase
+	Task     TaskUseCase
+	Schedule ScheduleUseCase
+	Webhook  WebhookUseCase
+	Workflow WorkflowUseCase
 }
 
 // NewUseCase создает новый экземпляр UseCase
-func NewUseCase(task TaskUseCase) *UseCase {
+func NewUseCase(task TaskUseCase, schedule ScheduleUseCase, webhook WebhookUseCase, workflow WorkflowUseCase) *UseCase {
 	return &UseCase{
-		Task: task,
+		Task:     task,
+		Schedule: schedule,
+		Webhook:  webhook,
+		Workflow: workflow,
 	}
 }
@@ -0,0 +1,273 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Egorpalan/workmate-test/config"
+	"github.com/Egorpalan/workmate-test/internal/entity"
+	"github.com/Egorpalan/workmate-test/internal/repository"
+	"github.com/Egorpalan/workmate-test/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// Заголовки запроса вебхука
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// webhookBatchSize ограничивает число доставок, отправляемых за один тик
+const webhookBatchSize = 20
+
+// webhookErrorBodyLimit ограничивает часть тела ответа получателя, сохраняемую в last_error
+const webhookErrorBodyLimit = 512
+
+var (
+	// errWebhookSecretMissing возвращается, если вебхук нельзя подписать, потому что не задан WEBHOOK_SECRET
+	errWebhookSecretMissing = errors.New("WEBHOOK_SECRET is not set, webhooks are disabled")
+	// errInvalidCallbackURL возвращается, если callback_url не является абсолютным http или https URL
+	errInvalidCallbackURL = errors.New("callback_url must be an absolute http or https URL")
+	// errPrivateWebhookTarget возвращается при попытке отправить вебхук на loopback, link-local или частный адрес
+	errPrivateWebhookTarget = errors.New("webhook target must be a public address")
+)
+
+type webhookUseCase struct {
+	webhookRepo repository.WebhookRepository
+	taskRepo    repository.TaskRepository
+	client      *http.Client
+	cfg         config.WebhookConfig
+	retry       RetryPolicy
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewWebhookUseCase создает новый экземпляр webhookUseCase.
+// Вебхуки всегда подписываются, поэтому если cfg.Secret пуст, задачи с callback_url не принимаются.
+func NewWebhookUseCase(
+	webhookRepo repository.WebhookRepository,
+	taskRepo repository.TaskRepository,
+	cfg config.WebhookConfig,
+) *webhookUseCase {
+	if cfg.Secret == "" {
+		logger.Warn("WEBHOOK_SECRET is not set, tasks with callback_url will be rejected")
+	}
+
+	return &webhookUseCase{
+		webhookRepo: webhookRepo,
+		taskRepo:    taskRepo,
+		client:      newWebhookClient(cfg),
+		cfg:         cfg,
+		retry:       NewRetryPolicy(cfg.Retry),
+	}
+}
+
+// newWebhookClient создает HTTP-клиент для доставки вебхуков. Клиент не следует редиректам, а если
+// cfg.AllowPrivateTargets не задан, отказывается подключаться к непубличным адресам — проверяется адрес
+// после разрешения имени, поэтому ограничение не обойти DNS-записью, указывающей во внутреннюю сеть.
+func newWebhookClient(cfg config.WebhookConfig) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !cfg.AllowPrivateTargets {
+		dialer := &net.Dialer{Timeout: cfg.Timeout, Control: dialPublicOnly}
+		transport.DialContext = dialer.DialContext
+		// Через прокси подключение шло бы к адресу прокси, а не получателя
+		transport.Proxy = nil
+	}
+
+	return &http.Client{
+		Timeout:   cfg.Timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// dialPublicOnly запрещает подключение к непубличным адресам, см. isPublicIP
+func dialPublicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+		return fmt.Errorf("%w: %s", errPrivateWebhookTarget, host)
+	}
+	return nil
+}
+
+// isPublicIP сообщает, что адрес не относится к loopback, link-local, частным, multicast или неуказанным адресам
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified()
+}
+
+// validateCallbackURL проверяет callback_url задачи при ее создании: вебхуки должны быть подписаны,
+// адрес — абсолютным http или https URL, а хост — не localhost и не непубличным IP-адресом.
+// Имена, которые разрешаются в непубличные адреса, отсекаются при отправке, см. newWebhookClient.
+func (u *webhookUseCase) validateCallbackURL(raw string) error {
+	if u.cfg.Secret == "" {
+		return errWebhookSecretMissing
+	}
+
+	callback, err := url.Parse(raw)
+	if err != nil || (callback.Scheme != "http" && callback.Scheme != "https") || callback.Host == "" {
+		return errInvalidCallbackURL
+	}
+	if u.cfg.AllowPrivateTargets {
+		return nil
+	}
+
+	host := strings.ToLower(callback.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: %s", errPrivateWebhookTarget, host)
+	}
+	if ip := net.ParseIP(host); ip != nil && !isPublicIP(ip) {
+		return fmt.Errorf("%w: %s", errPrivateWebhookTarget, host)
+	}
+
+	return nil
+}
+
+// Start запускает периодическую отправку ожидающих вебхуков
+func (u *webhookUseCase) Start(ctx context.Context) {
+	ctx, u.cancel = context.WithCancel(ctx)
+	runPeriodically(ctx, &u.wg, u.cfg.DispatchInterval, u.dispatchDue)
+}
+
+// Stop останавливает отправку и ждет завершения текущих доставок
+func (u *webhookUseCase) Stop(ctx context.Context) error {
+	if u.cancel != nil {
+		u.cancel()
+	}
+	return waitGroup(ctx, &u.wg)
+}
+
+// ListDeliveries возвращает доставки вебхуков задачи
+func (u *webhookUseCase) ListDeliveries(ctx context.Context, taskID string) ([]*entity.WebhookDelivery, error) {
+	_, err := u.taskRepo.GetByID(ctx, taskID)
+	if errors.Is(err, entity.ErrTaskNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		logger.Error("Failed to get task by ID", zap.String("id", taskID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get task by id: %w", err)
+	}
+
+	deliveries, err := u.webhookRepo.ListByTask(ctx, taskID)
+	if err != nil {
+		logger.Error("Failed to list webhook deliveries", zap.String("task_id", taskID), zap.Error(err))
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// dispatchDue отправляет все вебхуки, время попытки которых наступило
+func (u *webhookUseCase) dispatchDue(ctx context.Context) {
+	// Аренда с запасом перекрывает таймаут запроса, чтобы доставку не забрала другая реплика во время отправки
+	deliveries, err := u.webhookRepo.ClaimDue(ctx, webhookBatchSize, 2*u.cfg.Timeout)
+	if err != nil {
+		logger.Error("Failed to claim webhook deliveries", zap.Error(err))
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			u.deliver(ctx, delivery)
+		}()
+	}
+	wg.Wait()
+}
+
+// deliver выполняет одну попытку доставки и сохраняет ее результат.
+// Неудачная попытка планируется повторно с экспоненциальной задержкой, пока не исчерпан лимит попыток.
+func (u *webhookUseCase) deliver(ctx context.Context, delivery *entity.WebhookDelivery) {
+	statusCode, err := u.send(ctx, delivery)
+
+	now := time.Now()
+	delivery.LastStatusCode = statusCode
+	switch {
+	case err == nil:
+		delivery.Status = entity.WebhookStatusDelivered
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+	case delivery.Attempts >= delivery.MaxAttempts:
+		delivery.Status = entity.WebhookStatusFailed
+		delivery.LastError = err.Error()
+	default:
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = now.Add(u.retry.Backoff(delivery.Attempts))
+	}
+
+	if err != nil {
+		logger.Warn("Webhook delivery failed", zap.String("id", delivery.ID), zap.String("task_id", delivery.TaskID),
+			zap.Int("attempt", delivery.Attempts), zap.Error(err))
+	}
+
+	if err := u.webhookRepo.Update(context.WithoutCancel(ctx), delivery); err != nil {
+		logger.Error("Failed to save webhook delivery result", zap.String("id", delivery.ID), zap.Error(err))
+	}
+}
+
+// send отправляет подписанное тело вебхука получателю и возвращает код ответа.
+// Успешной считается доставка с ответом 2xx; редиректы не выполняются и считаются ошибкой.
+func (u *webhookUseCase) send(ctx context.Context, delivery *entity.WebhookDelivery) (int, error) {
+	if u.cfg.Secret == "" {
+		return 0, errWebhookSecretMissing
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to build webhook request: %w", err)
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, delivery.Event)
+	req.Header.Set(WebhookDeliveryHeader, delivery.ID)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(u.cfg.Secret, timestamp, delivery.Payload))
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, webhookErrorBodyLimit))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %d: %s", resp.StatusCode, body)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// SignWebhookPayload возвращает подпись вебхука: HMAC-SHA256 от строки "<timestamp>.<body>"
+// в шестнадцатеричном виде с префиксом "sha256=". Получатель проверяет ее тем же секретом.
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,197 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Egorpalan/workmate-test/config"
+	"github.com/Egorpalan/workmate-test/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockWebhookRepository struct {
+	mock.Mock
+}
+
+func (m *MockWebhookRepository) ClaimDue(
+	ctx context.Context,
+	limit int,
+	leaseDuration time.Duration,
+) ([]*entity.WebhookDelivery, error) {
+	args := m.Called(ctx, limit, leaseDuration)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*entity.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockWebhookRepository) Update(ctx context.Context, delivery *entity.WebhookDelivery) error {
+	args := m.Called(ctx, delivery)
+	return args.Error(0)
+}
+
+func (m *MockWebhookRepository) ListByTask(ctx context.Context, taskID string) ([]*entity.WebhookDelivery, error) {
+	args := m.Called(ctx, taskID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*entity.WebhookDelivery), args.Error(1)
+}
+
+// testWebhookConfig возвращает конфигурацию вебхуков для тестов
+func testWebhookConfig() config.WebhookConfig {
+	return config.WebhookConfig{
+		Secret:              "test-secret",
+		Timeout:             time.Second,
+		DispatchInterval:    time.Hour,
+		AllowPrivateTargets: true,
+		Retry:               config.RetryConfig{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute},
+	}
+}
+
+// TestValidateCallbackURL тестирует проверку callback_url при создании задачи
+func TestValidateCallbackURL(t *testing.T) {
+	cfg := testWebhookConfig()
+	cfg.AllowPrivateTargets = false
+	noSecret := cfg
+	noSecret.Secret = ""
+
+	tests := []struct {
+		name    string
+		cfg     config.WebhookConfig
+		url     string
+		wantErr error
+	}{
+		{"public", cfg, "https://example.com/hook", nil},
+		{"public ip", cfg, "http://93.184.216.34/hook", nil},
+		{"not http", cfg, "ftp://example.com/hook", errInvalidCallbackURL},
+		{"loopback", cfg, "http://127.0.0.1:8080/hook", errPrivateWebhookTarget},
+		{"localhost", cfg, "http://localhost/hook", errPrivateWebhookTarget},
+		{"private", cfg, "http://10.0.0.5/hook", errPrivateWebhookTarget},
+		{"link-local", cfg, "http://169.254.169.254/latest/meta-data", errPrivateWebhookTarget},
+		{"ipv6 loopback", cfg, "http://[::1]/hook", errPrivateWebhookTarget},
+		{"no secret", noSecret, "https://example.com/hook", errWebhookSecretMissing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useCase := NewWebhookUseCase(new(MockWebhookRepository), new(MockTaskRepository), tt.cfg)
+
+			err := useCase.validateCallbackURL(tt.url)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+// TestWebhookDeliver тестирует отправку подписанного вебхука получателю
+func TestWebhookDeliver(t *testing.T) {
+	payload := json.RawMessage(`{"id":"task-id","status":"completed"}`)
+
+	received := make(chan *http.Request, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(WebhookTimestampHeader), 10, 64)
+		if r.Header.Get(WebhookSignatureHeader) != SignWebhookPayload("test-secret", timestamp, body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		received <- r
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	mockRepo := new(MockWebhookRepository)
+	mockRepo.On("Update", mock.Anything, mock.AnythingOfType("*entity.WebhookDelivery")).Return(nil)
+
+	useCase := NewWebhookUseCase(mockRepo, new(MockTaskRepository), testWebhookConfig())
+
+	delivery := &entity.WebhookDelivery{
+		ID:          "delivery-id",
+		URL:         receiver.URL,
+		Event:       entity.WebhookEventTaskCompleted,
+		Payload:     payload,
+		Status:      entity.WebhookStatusPending,
+		Attempts:    1,
+		MaxAttempts: 3,
+	}
+	useCase.deliver(context.Background(), delivery)
+
+	req := <-received
+	assert.Equal(t, entity.WebhookEventTaskCompleted, req.Header.Get(WebhookEventHeader))
+	assert.Equal(t, entity.WebhookStatusDelivered, delivery.Status)
+	assert.Equal(t, http.StatusNoContent, delivery.LastStatusCode)
+	assert.NotNil(t, delivery.DeliveredAt)
+}
+
+// TestWebhookDeliver_Retry тестирует повтор неудачной доставки с задержкой и отказ после исчерпания попыток
+func TestWebhookDeliver_Retry(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	mockRepo := new(MockWebhookRepository)
+	mockRepo.On("Update", mock.Anything, mock.AnythingOfType("*entity.WebhookDelivery")).Return(nil)
+
+	useCase := NewWebhookUseCase(mockRepo, new(MockTaskRepository), testWebhookConfig())
+
+	delivery := &entity.WebhookDelivery{URL: receiver.URL, Status: entity.WebhookStatusPending, Attempts: 2, MaxAttempts: 3}
+	before := time.Now()
+	useCase.deliver(context.Background(), delivery)
+
+	assert.Equal(t, entity.WebhookStatusPending, delivery.Status)
+	assert.Equal(t, http.StatusServiceUnavailable, delivery.LastStatusCode)
+	assert.WithinDuration(t, before.Add(2*time.Second), delivery.NextAttemptAt, 100*time.Millisecond)
+
+	delivery.Attempts = 3
+	useCase.deliver(context.Background(), delivery)
+
+	assert.Equal(t, entity.WebhookStatusFailed, delivery.Status)
+	assert.Contains(t, delivery.LastError, "503")
+}
+
+// TestWebhookDeliver_Guarded тестирует отказ отправлять вебхук на непубличный адрес и следовать редиректам
+func TestWebhookDeliver_Guarded(t *testing.T) {
+	redirected := make(chan struct{}, 1)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirected <- struct{}{}
+	}))
+	defer target.Close()
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusFound)
+	}))
+	defer receiver.Close()
+
+	mockRepo := new(MockWebhookRepository)
+	mockRepo.On("Update", mock.Anything, mock.AnythingOfType("*entity.WebhookDelivery")).Return(nil)
+
+	useCase := NewWebhookUseCase(mockRepo, new(MockTaskRepository), testWebhookConfig())
+	delivery := &entity.WebhookDelivery{URL: receiver.URL, Status: entity.WebhookStatusPending, Attempts: 1, MaxAttempts: 3}
+	useCase.deliver(context.Background(), delivery)
+
+	assert.Equal(t, http.StatusFound, delivery.LastStatusCode)
+	assert.Equal(t, entity.WebhookStatusPending, delivery.Status)
+	assert.Len(t, redirected, 0)
+
+	cfg := testWebhookConfig()
+	cfg.AllowPrivateTargets = false
+	useCase = NewWebhookUseCase(mockRepo, new(MockTaskRepository), cfg)
+	delivery = &entity.WebhookDelivery{URL: target.URL, Status: entity.WebhookStatusPending, Attempts: 1, MaxAttempts: 3}
+	useCase.deliver(context.Background(), delivery)
+
+	assert.Equal(t, 0, delivery.LastStatusCode)
+	assert.Contains(t, delivery.LastError, errPrivateWebhookTarget.Error())
+	assert.Len(t, redirected, 0)
+}
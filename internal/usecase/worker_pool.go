@@ -0,0 +1,99 @@
+package usecase
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// WorkFunc выполняет одну единицу работы и возвращает true, если работа была найдена
+type WorkFunc func(ctx context.Context) bool
+
+// WorkerPool представляет пул воркеров фиксированного размера, разбирающих общую очередь.
+// Когда работы нет, воркеры засыпают до сигнала Notify или до следующего опроса.
+type WorkerPool struct {
+	workers      int
+	pollInterval time.Duration
+	wake         chan struct{}
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewWorkerPool создает новый пул с заданным числом воркеров и интервалом опроса очереди
+func NewWorkerPool(workers int, pollInterval time.Duration) *WorkerPool {
+	if workers <= 0 {
+		workers = 1
+	}
+	if pollInterval <= 0 {
+		pollInterval = time.Second
+	}
+
+	return &WorkerPool{
+		workers:      workers,
+		pollInterval: pollInterval,
+		wake:         make(chan struct{}, workers),
+	}
+}
+
+// Start запускает воркеры, которые вызывают work, пока она находит задачи
+func (p *WorkerPool) Start(ctx context.Context, work WorkFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	p.mu.Lock()
+	p.cancel = cancel
+	p.mu.Unlock()
+
+	for i := 0; i < p.workers; i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+
+			ticker := time.NewTicker(p.pollInterval)
+			defer ticker.Stop()
+
+			for {
+				for ctx.Err() == nil && work(ctx) {
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-p.wake:
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+}
+
+// Notify будит один из простаивающих воркеров, не блокируя вызывающего
+func (p *WorkerPool) Notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Stop отменяет контекст воркеров и ждет их завершения
+func (p *WorkerPool) Stop(ctx context.Context) error {
+	p.mu.Lock()
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
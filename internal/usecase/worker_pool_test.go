@@ -0,0 +1,60 @@
+package usecase
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWorkerPool_LimitsConcurrency тестирует, что одновременно работает не больше воркеров, чем задано
+func TestWorkerPool_LimitsConcurrency(t *testing.T) {
+	pool := NewWorkerPool(2, time.Hour)
+
+	var running, maxRunning, remaining int32 = 0, 0, 5
+	release := make(chan struct{})
+
+	pool.Start(context.Background(), func(ctx context.Context) bool {
+		if atomic.AddInt32(&remaining, -1) < 0 {
+			return false
+		}
+
+		current := atomic.AddInt32(&running, 1)
+		for {
+			prev := atomic.LoadInt32(&maxRunning)
+			if current <= prev || atomic.CompareAndSwapInt32(&maxRunning, prev, current) {
+				break
+			}
+		}
+		<-release
+		atomic.AddInt32(&running, -1)
+		return true
+	})
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&remaining) < 0 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&maxRunning))
+	assert.NoError(t, pool.Stop(context.Background()))
+}
+
+// TestWorkerPool_Notify тестирует пробуждение простаивающего воркера по сигналу
+func TestWorkerPool_Notify(t *testing.T) {
+	pool := NewWorkerPool(1, time.Hour)
+
+	var calls int32
+	pool.Start(context.Background(), func(ctx context.Context) bool {
+		atomic.AddInt32(&calls, 1)
+		return false
+	})
+
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&calls) == 1 }, time.Second, 10*time.Millisecond)
+
+	pool.Notify()
+
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&calls) == 2 }, time.Second, 10*time.Millisecond)
+	assert.NoError(t, pool.Stop(context.Background()))
+}
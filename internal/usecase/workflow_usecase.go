@@ -0,0 +1,216 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Egorpalan/workmate-test/internal/entity"
+	"github.com/Egorpalan/workmate-test/internal/repository"
+	"github.com/Egorpalan/workmate-test/pkg/logger"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrInvalidWorkflowSpec возвращается при некорректных параметрах создания workflow
+	ErrInvalidWorkflowSpec = errors.New("invalid workflow spec")
+	// ErrWorkflowNotCancellable возвращается при попытке отменить workflow, все шаги которого уже завершены
+	ErrWorkflowNotCancellable = errors.New("workflow has no unfinished steps")
+)
+
+// maxWorkflowStepNameLength ограничивает длину имени шага, см. колонку tasks.workflow_step
+const maxWorkflowStepNameLength = 100
+
+// workflowTasks — операции над задачами, через которые workflowUseCase создает и отменяет шаги.
+// Реализуется taskUseCase.
+type workflowTasks interface {
+	// validateSpec проверяет параметры задачи шага
+	validateSpec(spec entity.TaskSpec) error
+	// createTasks собирает задачи по specs, сохраняет их через insert и рассылает события о них
+	createTasks(
+		ctx context.Context,
+		specs []entity.TaskSpec,
+		insert func(tasks []*entity.Task) error,
+	) ([]*entity.Task, error)
+	// finishCancel доводит до конца отмену задачи, записанную в репозиторий
+	finishCancel(ctx context.Context, task *entity.Task)
+}
+
+type workflowUseCase struct {
+	workflowRepo repository.WorkflowRepository
+	tasks        workflowTasks
+}
+
+// NewWorkflowUseCase создает новый экземпляр workflowUseCase. Задачи шагов проверяются и создаются через tasks.
+func NewWorkflowUseCase(workflowRepo repository.WorkflowRepository, tasks workflowTasks) *workflowUseCase {
+	return &workflowUseCase{
+		workflowRepo: workflowRepo,
+		tasks:        tasks,
+	}
+}
+
+// CreateWorkflow создает workflow и задачи всех его шагов. Шаги без предшественников сразу ставятся в очередь
+// и получают в payload spec.Input, остальные ждут предшественников в статусе blocked.
+func (u *workflowUseCase) CreateWorkflow(ctx context.Context, spec entity.WorkflowSpec) (*entity.Workflow, error) {
+	steps, err := u.normalizeSteps(spec.Steps)
+	if err != nil {
+		return nil, err
+	}
+
+	workflow := &entity.Workflow{
+		Name:  spec.Name,
+		Input: spec.Input,
+		Steps: steps,
+	}
+
+	// Зависимости задаются именами шагов, репозиторий заменяет их на ID созданных задач
+	specs := make([]entity.TaskSpec, 0, len(steps))
+	for _, step := range steps {
+		taskSpec := stepTaskSpec(step)
+		taskSpec.DependsOn = step.After
+		if len(step.After) == 0 {
+			taskSpec.Payload = spec.Input
+		}
+		specs = append(specs, taskSpec)
+	}
+
+	_, err = u.tasks.createTasks(ctx, specs, func(tasks []*entity.Task) error {
+		if err := u.workflowRepo.Create(ctx, workflow, tasks); err != nil {
+			logger.Error("Failed to create workflow", zap.Error(err))
+			return fmt.Errorf("failed to create workflow: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	workflow.Status = workflowStatus(workflow.Tasks)
+
+	return workflow, nil
+}
+
+// normalizeSteps проверяет шаги workflow и возвращает их копию, в которой у каждого шага явно указаны
+// предшественники: шаг без after идет после предыдущего. Ссылаться можно только на предыдущие шаги,
+// поэтому шаги workflow не образуют циклов.
+func (u *workflowUseCase) normalizeSteps(specs entity.WorkflowSteps) (entity.WorkflowSteps, error) {
+	if len(specs) == 0 || len(specs) > entity.MaxWorkflowSteps {
+		return nil, fmt.Errorf("%w: workflow must contain from 1 to %d steps",
+			ErrInvalidWorkflowSpec, entity.MaxWorkflowSteps)
+	}
+
+	steps := make(entity.WorkflowSteps, 0, len(specs))
+	seen := make(map[string]struct{}, len(specs))
+	for i, step := range specs {
+		if step.Name == "" || len(step.Name) > maxWorkflowStepNameLength {
+			return nil, fmt.Errorf("%w: step %d: name must be from 1 to %d characters long",
+				ErrInvalidWorkflowSpec, i, maxWorkflowStepNameLength)
+		}
+		if _, ok := seen[step.Name]; ok {
+			return nil, fmt.Errorf("%w: duplicate step name %q", ErrInvalidWorkflowSpec, step.Name)
+		}
+		if err := u.tasks.validateSpec(stepTaskSpec(step)); err != nil {
+			return nil, fmt.Errorf("step %q: %w", step.Name, err)
+		}
+
+		switch {
+		case step.After == nil && i > 0:
+			step.After = []string{specs[i-1].Name}
+		case step.After == nil:
+			step.After = []string{}
+		default:
+			step.After = uniqueIDs(step.After)
+			for _, name := range step.After {
+				if _, ok := seen[name]; !ok {
+					return nil, fmt.Errorf("%w: step %q must come after earlier steps only, got %q",
+						ErrInvalidWorkflowSpec, step.Name, name)
+				}
+			}
+		}
+
+		seen[step.Name] = struct{}{}
+		steps = append(steps, step)
+	}
+
+	return steps, nil
+}
+
+// stepTaskSpec возвращает параметры создания задачи шага workflow
+func stepTaskSpec(step entity.WorkflowStep) entity.TaskSpec {
+	return entity.TaskSpec{
+		Type:           step.Type,
+		Queue:          step.Queue,
+		TimeoutSeconds: step.TimeoutSeconds,
+		Priority:       step.Priority,
+	}
+}
+
+// GetWorkflow возвращает workflow по его ID с задачами шагов и статусом, вычисленным по ним
+func (u *workflowUseCase) GetWorkflow(ctx context.Context, id string) (*entity.Workflow, error) {
+	workflow, err := u.workflowRepo.GetByID(ctx, id)
+	if errors.Is(err, entity.ErrWorkflowNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		logger.Error("Failed to get workflow by ID", zap.String("id", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to get workflow by id: %w", err)
+	}
+
+	workflow.Status = workflowStatus(workflow.Tasks)
+	return workflow, nil
+}
+
+// CancelWorkflow отменяет все незавершенные шаги workflow и их дочерние задачи: ожидающие сразу переходят
+// в cancelled, а выполняемым передается запрос отмены, как в CancelTask
+func (u *workflowUseCase) CancelWorkflow(ctx context.Context, id string) (*entity.Workflow, error) {
+	tasks, err := u.workflowRepo.Cancel(ctx, id)
+	if errors.Is(err, entity.ErrWorkflowNotFound) {
+		return nil, err
+	}
+	if errors.Is(err, entity.ErrTaskFinished) {
+		return nil, ErrWorkflowNotCancellable
+	}
+	if err != nil {
+		logger.Error("Failed to cancel workflow", zap.String("id", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to cancel workflow: %w", err)
+	}
+
+	// Отмененные шаги могут ждать задачи вне workflow, созданные с depends_on, поэтому граф продвигается как в CancelTask
+	for _, task := range tasks {
+		u.tasks.finishCancel(ctx, task)
+	}
+
+	return u.GetWorkflow(ctx, id)
+}
+
+// workflowStatus вычисляет статус workflow по задачам его шагов. Пока есть незавершенные шаги,
+// workflow ждет (pending) или выполняется (running); после завершения всех шагов он считается упавшим,
+// если упал хотя бы один шаг, отмененным, если хотя бы один отменен, и выполненным в остальных случаях.
+func workflowStatus(tasks []*entity.Task) entity.WorkflowStatus {
+	var started, failed, cancelled, unfinished bool
+	for _, task := range tasks {
+		switch task.Status {
+		case entity.TaskStatusProcessing, entity.TaskStatusCompleted:
+			started = true
+		case entity.TaskStatusFailed, entity.TaskStatusDead:
+			started, failed = true, true
+		case entity.TaskStatusCancelled:
+			cancelled = true
+		}
+		if !task.Status.IsTerminal() {
+			unfinished = true
+		}
+	}
+
+	switch {
+	case unfinished && started:
+		return entity.WorkflowStatusRunning
+	case unfinished:
+		return entity.WorkflowStatusPending
+	case failed:
+		return entity.WorkflowStatusFailed
+	case cancelled:
+		return entity.WorkflowStatusCancelled
+	default:
+		return entity.WorkflowStatusCompleted
+	}
+}
@@ -0,0 +1,179 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/Egorpalan/workmate-test/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockWorkflowRepository struct {
+	mock.Mock
+}
+
+func (m *MockWorkflowRepository) Create(ctx context.Context, workflow *entity.Workflow, tasks []*entity.Task) error {
+	args := m.Called(ctx, workflow, tasks)
+	workflow.ID = "workflow-id"
+	for i, task := range tasks {
+		task.ID = fmt.Sprintf("mock-id-%d", i)
+		task.WorkflowStep = workflow.Steps[i].Name
+		if len(workflow.Steps[i].After) > 0 {
+			task.Status = entity.TaskStatusBlocked
+		}
+	}
+	workflow.Tasks = tasks
+	return args.Error(0)
+}
+
+func (m *MockWorkflowRepository) GetByID(ctx context.Context, id string) (*entity.Workflow, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Workflow), args.Error(1)
+}
+
+func (m *MockWorkflowRepository) Cancel(ctx context.Context, id string) ([]*entity.Task, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*entity.Task), args.Error(1)
+}
+
+func newTestWorkflowUseCase(repo *MockWorkflowRepository, taskRepo *MockTaskRepository) *workflowUseCase {
+	return NewWorkflowUseCase(repo, newTestNoopTaskUseCase(taskRepo))
+}
+
+// TestCreateWorkflow проверяет порядок шагов по умолчанию, ветвление и передачу input первому шагу
+func TestCreateWorkflow(t *testing.T) {
+	mockRepo := new(MockWorkflowRepository)
+	mockTaskRepo := new(MockTaskRepository)
+	mockTaskRepo.On("CountByStatus", mock.Anything, entity.TaskStatusPending).Return(0, nil)
+	mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*entity.Workflow"), mock.Anything).Return(nil)
+
+	useCase := newTestWorkflowUseCase(mockRepo, mockTaskRepo)
+
+	input := json.RawMessage(`{"to":"user@example.com"}`)
+	workflow, err := useCase.CreateWorkflow(context.Background(), entity.WorkflowSpec{
+		Name:  "report",
+		Input: input,
+		Steps: entity.WorkflowSteps{
+			{Name: "fetch", Type: "test"},
+			{Name: "render", Type: "test"},
+			{Name: "archive", Type: "test", After: []string{"fetch"}},
+			{Name: "notify", Type: "test", After: []string{"render", "archive"}},
+		},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "workflow-id", workflow.ID)
+	assert.Equal(t, entity.WorkflowStatusPending, workflow.Status)
+	assert.Equal(t, []string{}, workflow.Steps[0].After)
+	assert.Equal(t, []string{"fetch"}, workflow.Steps[1].After)
+	assert.Equal(t, []string{"render", "archive"}, workflow.Steps[3].After)
+	if assert.Len(t, workflow.Tasks, 4) {
+		assert.Equal(t, input, workflow.Tasks[0].Payload)
+		assert.Equal(t, entity.TaskStatusPending, workflow.Tasks[0].Status)
+		assert.Nil(t, workflow.Tasks[1].Payload)
+		assert.Equal(t, entity.TaskStatusBlocked, workflow.Tasks[3].Status)
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+// TestCreateWorkflow_Invalid проверяет отклонение некорректных шагов до обращения к базе
+func TestCreateWorkflow_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   entity.WorkflowSteps
+		wantErr error
+	}{
+		{"no steps", nil, ErrInvalidWorkflowSpec},
+		{"empty name", entity.WorkflowSteps{{Type: "test"}}, ErrInvalidWorkflowSpec},
+		{"duplicate name", entity.WorkflowSteps{{Name: "a", Type: "test"}, {Name: "a", Type: "test"}}, ErrInvalidWorkflowSpec},
+		{"later step", entity.WorkflowSteps{{Name: "a", Type: "test", After: []string{"b"}}, {Name: "b", Type: "test"}}, ErrInvalidWorkflowSpec},
+		{"self", entity.WorkflowSteps{{Name: "a", Type: "test", After: []string{"a"}}}, ErrInvalidWorkflowSpec},
+		{"unknown type", entity.WorkflowSteps{{Name: "a", Type: "unknown"}}, ErrUnknownTaskType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockWorkflowRepository)
+			useCase := newTestWorkflowUseCase(mockRepo, new(MockTaskRepository))
+
+			_, err := useCase.CreateWorkflow(context.Background(), entity.WorkflowSpec{Steps: tt.steps})
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+// TestWorkflowStatus проверяет вычисление статуса workflow по статусам задач шагов
+func TestWorkflowStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []entity.TaskStatus
+		want     entity.WorkflowStatus
+	}{
+		{"not started", []entity.TaskStatus{entity.TaskStatusPending, entity.TaskStatusBlocked}, entity.WorkflowStatusPending},
+		{"running", []entity.TaskStatus{entity.TaskStatusCompleted, entity.TaskStatusPending}, entity.WorkflowStatusRunning},
+		{"branch failed", []entity.TaskStatus{entity.TaskStatusFailed, entity.TaskStatusProcessing}, entity.WorkflowStatusRunning},
+		{"completed", []entity.TaskStatus{entity.TaskStatusCompleted, entity.TaskStatusCompleted}, entity.WorkflowStatusCompleted},
+		{"failed", []entity.TaskStatus{entity.TaskStatusDead, entity.TaskStatusFailed}, entity.WorkflowStatusFailed},
+		{"cancelled", []entity.TaskStatus{entity.TaskStatusCompleted, entity.TaskStatusCancelled}, entity.WorkflowStatusCancelled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tasks := make([]*entity.Task, 0, len(tt.statuses))
+			for _, status := range tt.statuses {
+				tasks = append(tasks, &entity.Task{Status: status})
+			}
+
+			assert.Equal(t, tt.want, workflowStatus(tasks))
+		})
+	}
+}
+
+// TestCancelWorkflow проверяет отмену оставшихся шагов, перевод в failed задач вне workflow, ждущих отмененный шаг,
+// и отказ отменять завершенный workflow
+func TestCancelWorkflow(t *testing.T) {
+	mockRepo := new(MockWorkflowRepository)
+	mockRepo.On("Cancel", mock.Anything, "workflow-id").Return([]*entity.Task{
+		{ID: "task-2", Status: entity.TaskStatusCancelled},
+		{ID: "task-3", Status: entity.TaskStatusCancelled},
+	}, nil).Once()
+	mockRepo.On("Cancel", mock.Anything, "workflow-id").Return(nil, entity.ErrTaskFinished)
+	mockRepo.On("GetByID", mock.Anything, "workflow-id").Return(&entity.Workflow{
+		ID: "workflow-id",
+		Tasks: []*entity.Task{
+			{ID: "task-1", Status: entity.TaskStatusCompleted},
+			{ID: "task-2", Status: entity.TaskStatusCancelled},
+			{ID: "task-3", Status: entity.TaskStatusCancelled},
+		},
+	}, nil)
+
+	mockTaskRepo := new(MockTaskRepository)
+	mockTaskRepo.On("CancelChildren", mock.Anything, mock.Anything).Return([]*entity.Task{}, nil)
+	mockTaskRepo.On("FailDependents", mock.Anything, "task-2").Return([]*entity.Task{
+		{ID: "external", Status: entity.TaskStatusFailed},
+	}, nil)
+	mockTaskRepo.On("FailDependents", mock.Anything, "task-3").Return([]*entity.Task{}, nil)
+
+	useCase := newTestWorkflowUseCase(mockRepo, mockTaskRepo)
+
+	workflow, err := useCase.CancelWorkflow(context.Background(), "workflow-id")
+	assert.NoError(t, err)
+	assert.Equal(t, entity.WorkflowStatusCancelled, workflow.Status)
+	mockTaskRepo.AssertCalled(t, "FailDependents", mock.Anything, "task-2")
+	mockTaskRepo.AssertCalled(t, "FailDependents", mock.Anything, "task-3")
+
+	_, err = useCase.CancelWorkflow(context.Background(), "workflow-id")
+	assert.ErrorIs(t, err, ErrWorkflowNotCancellable)
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_tasks_pending_created_at ON tasks(created_at) WHERE status = 'pending';
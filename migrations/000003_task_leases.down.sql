@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_tasks_processing_locked_until;
+
+ALTER TABLE tasks
+    DROP COLUMN IF EXISTS attempts,
+    DROP COLUMN IF EXISTS locked_until,
+    DROP COLUMN IF EXISTS worker_id;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_tasks_pending_next_run_at;
+CREATE INDEX IF NOT EXISTS idx_tasks_pending_created_at ON tasks(created_at) WHERE status = 'pending';
+
+ALTER TABLE tasks
+    DROP COLUMN IF EXISTS attempt_history,
+    DROP COLUMN IF EXISTS next_run_at,
+    DROP COLUMN IF EXISTS max_attempts;
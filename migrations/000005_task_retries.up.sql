@@ -0,0 +1,7 @@
+ALTER TABLE tasks
+    ADD COLUMN IF NOT EXISTS max_attempts INTEGER NOT NULL DEFAULT 3,
+    ADD COLUMN IF NOT EXISTS next_run_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    ADD COLUMN IF NOT EXISTS attempt_history JSONB NOT NULL DEFAULT '[]'::jsonb;
+
+DROP INDEX IF EXISTS idx_tasks_pending_created_at;
+CREATE INDEX IF NOT EXISTS idx_tasks_pending_next_run_at ON tasks(next_run_at, created_at) WHERE status = 'pending';
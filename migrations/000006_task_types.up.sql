@@ -0,0 +1,8 @@
+-- Существующие задачи создавались единственным обработчиком, который теперь зарегистрирован как report.generate
+ALTER TABLE tasks
+    ADD COLUMN IF NOT EXISTS type VARCHAR(100) NOT NULL DEFAULT 'report.generate';
+
+ALTER TABLE tasks
+    ALTER COLUMN type DROP DEFAULT;
+
+CREATE INDEX IF NOT EXISTS idx_tasks_type ON tasks(type);
@@ -0,0 +1,3 @@
+ALTER TABLE tasks
+    DROP COLUMN IF EXISTS error_code,
+    DROP COLUMN IF EXISTS timeout_seconds;
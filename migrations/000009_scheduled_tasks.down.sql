@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_tasks_scheduled_next_run_at;
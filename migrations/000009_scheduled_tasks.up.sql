@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_tasks_scheduled_next_run_at ON tasks(next_run_at) WHERE status = 'scheduled';
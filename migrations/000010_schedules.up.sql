@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS schedules (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(200) NOT NULL DEFAULT '',
+    cron_expression VARCHAR(100) NOT NULL,
+    task_type VARCHAR(100) NOT NULL,
+    payload JSONB,
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_run_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_schedules_enabled_next_run_at ON schedules(next_run_at) WHERE enabled;
+
+ALTER TABLE tasks
+    ADD COLUMN IF NOT EXISTS schedule_id UUID REFERENCES schedules(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_tasks_schedule_id ON tasks(schedule_id);
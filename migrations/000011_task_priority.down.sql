@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_tasks_status_priority_created_at;
+
+ALTER TABLE tasks DROP COLUMN IF EXISTS priority;
@@ -0,0 +1,3 @@
+ALTER TABLE tasks
+    DROP COLUMN IF EXISTS progress,
+    DROP COLUMN IF EXISTS progress_message;
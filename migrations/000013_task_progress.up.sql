@@ -0,0 +1,3 @@
+ALTER TABLE tasks
+    ADD COLUMN IF NOT EXISTS progress SMALLINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS progress_message TEXT NOT NULL DEFAULT '';
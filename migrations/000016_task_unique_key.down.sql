@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_tasks_unique_key_unfinished;
+
+ALTER TABLE tasks DROP COLUMN IF EXISTS unique_key;
@@ -0,0 +1,4 @@
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS unique_key VARCHAR(255) NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tasks_unique_key_unfinished ON tasks(type, unique_key)
+    WHERE unique_key <> '' AND status IN ('scheduled', 'pending', 'processing');
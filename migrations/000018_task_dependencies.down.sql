@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_tasks_unique_key_unfinished;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tasks_unique_key_unfinished ON tasks(type, unique_key)
+    WHERE unique_key <> '' AND status IN ('scheduled', 'pending', 'processing');
+
+UPDATE tasks SET status = 'pending', updated_at = NOW() WHERE status = 'blocked';
+
+DROP TABLE IF EXISTS task_dependencies;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS task_dependencies (
+    task_id UUID NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    depends_on UUID NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    PRIMARY KEY (task_id, depends_on)
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_dependencies_depends_on ON task_dependencies(depends_on);
+
+-- Ожидающая зависимостей задача тоже считается незавершенной для unique_key
+DROP INDEX IF EXISTS idx_tasks_unique_key_unfinished;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tasks_unique_key_unfinished ON tasks(type, unique_key)
+    WHERE unique_key <> '' AND status IN ('blocked', 'scheduled', 'pending', 'processing');
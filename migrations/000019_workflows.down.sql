@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_tasks_workflow_id;
+
+ALTER TABLE tasks
+    DROP COLUMN IF EXISTS workflow_step,
+    DROP COLUMN IF EXISTS workflow_id;
+
+DROP TABLE IF EXISTS workflows;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_tasks_pending_queue_next_run_at;
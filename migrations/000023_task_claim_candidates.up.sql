@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_tasks_pending_queue_next_run_at ON tasks(queue, next_run_at, created_at) WHERE status = 'pending';